@@ -39,78 +39,20 @@ const apiKey = "78f9704baaab411a87edeed59052cbb687a4aa7764a44accbaf6447492b0ca8c
 
 func main() {
 	key := ambient.NewKey(applicationKey, apiKey)
-	dr, err := ambient.Device(key)
+	// 429, 502 and 503 responses are retried by the client.
+	client := ambient.NewClient(ambient.WithRetryPolicy(ambient.DefaultRetryPolicy()))
+	dr, err := client.Device(key)
 	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "HTTPResponseCode=%d\n", dr.HTTPResponseCode)
 		panic(err)
 	}
-	switch dr.HTTPResponseCode {
-	case 200:
-	case 429, 502, 503:
-		{
-			fmt.Printf("Error code %d, retrying.\n", dr.HTTPResponseCode)
-			time.Sleep(1 * time.Second)
-			dr, err = ambient.Device(key)
-			if err != nil {
-				panic(err)
-			}
-			switch dr.HTTPResponseCode {
-			case 200:
-			default:
-				{
-					panic(dr)
-				}
-			}
-		}
-	default:
-		{
-			_, err := fmt.Fprintf(os.Stderr, "HTTPResponseCode=%d\n", dr.HTTPResponseCode)
-			if err != nil {
-				return
-			}
-			panic(dr)
-		}
-	}
 	ar := make([]ambient.APIDeviceMacResponse, len(dr.DeviceRecord))
 	for z := range dr.DeviceRecord {
-		// API RateLimit
-		time.Sleep(1 * time.Second)
-		ar2, err := ambient.DeviceMac(key, dr.DeviceRecord[z].Macaddress, time.Now(), 1)
+		ar[z], err = client.DeviceMac(key, dr.DeviceRecord[z].Macaddress, time.Now(), 1)
 		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "HTTPResponseCode=%d\n", ar[z].HTTPResponseCode)
 			panic(err)
 		}
-		ar[z] = ar2
-		switch ar[z].HTTPResponseCode {
-		case 200:
-		case 429, 502, 503:
-			{
-				fmt.Printf("Error code %d, retrying.\n", ar[z].HTTPResponseCode)
-				time.Sleep(1 * time.Second)
-				ar2, err = ambient.DeviceMac(key, dr.DeviceRecord[z].Macaddress, time.Now(), 1)
-				if err != nil {
-					panic(err)
-				}
-				ar[z] = ar2
-				switch ar[z].HTTPResponseCode {
-				case 200:
-				default:
-					{
-						_, err := fmt.Fprintf(os.Stderr, "HTTPResponseCode=%d\n", ar[z].HTTPResponseCode)
-						if err != nil {
-							return
-						}
-						panic(ar)
-					}
-				}
-			}
-		default:
-			{
-				_, err := fmt.Fprintf(os.Stderr, "HTTPResponseCode=%d\n", ar[z].HTTPResponseCode)
-				if err != nil {
-					return
-				}
-				panic(ar)
-			}
-		}
 	}
 	var drPrettyJSON bytes.Buffer
 	err = json.Indent(&drPrettyJSON, dr.JSONResponse, "", "\t")
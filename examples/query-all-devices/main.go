@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"github.com/lrosenman/ambient/pkg/ambient"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -29,19 +33,22 @@ var (
 func main() {
 	flag.Parse()
 
+	// Stop cleanly, including any call in flight, on Ctrl-C or SIGTERM.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	key := ambient.NewKey(*applicationKey, *apiKey)
-	devices, err := ambient.Device(key)
+	client := ambient.NewClient(ambient.WithRetryPolicy(ambient.DefaultRetryPolicy()))
+	devices, err := client.DeviceContext(ctx, key)
 	if err != nil {
 		log.Panicln("unable to retrieve devices")
 	}
 
 	endDate := time.Now().UTC()
 	for _, device := range devices.DeviceRecord {
-		// Ensuring the rate limit is not exceeded per https://ambientweather.docs.apiary.io/#introduction/rate-limiting
-		time.Sleep(1 * time.Second)
-
+		// The client's rate limiter ensures the limit is not exceeded per https://ambientweather.docs.apiary.io/#introduction/rate-limiting
 		log.Printf("Querying device '%s'", device.Macaddress)
-		queryResults, queryErr := ambient.DeviceMac(key, device.Macaddress, endDate, *maxNumberOfResults)
+		queryResults, queryErr := client.DeviceMacContext(ctx, key, device.Macaddress, endDate, *maxNumberOfResults)
 		if queryErr != nil {
 			log.Panicf("error when querying device '%s' %v", device.Macaddress, queryErr)
 		}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/lrosenman/ambient/pkg/realtime"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+/*
+This example subscribes to the realtime API and logs every observation as it arrives.
+To generate an application and api key for your own account, do so at https://ambientweather.net/account in the API Keys section.
+
+API Docs:
+https://ambientweather.docs.apiary.io/#reference/ambient-realtime-api
+
+Sample Usage:
+go run main.go -applicationKey AFEA804E-9AB8-4E4F-BBCC-276C413E8B84 -apiKey F362D94E-FB4C-434F-A9B3-D4A2694CF6A4
+*/
+
+var (
+	applicationKey = flag.String("applicationKey", "", "Ambient Weather Application Key")
+	apiKey         = flag.String("apiKey", "", "Ambient Weather API Key")
+)
+
+func main() {
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client := realtime.NewClient(*applicationKey)
+	if err := client.Subscribe(ctx, *apiKey); err != nil {
+		log.Panicf("unable to subscribe: %v", err)
+	}
+	go func() {
+		_ = client.Run(ctx)
+	}()
+
+	for event := range client.Events() {
+		switch event.Type {
+		case realtime.EventSubscribed:
+			log.Printf("%v devices subscribed", len(event.Devices))
+		case realtime.EventData:
+			log.Printf("Mac: '%s' Recorded At: %v Temperature: %v", event.MacAddress, event.Record.Date, event.Record.Tempf)
+		case realtime.EventDisconnected:
+			log.Printf("disconnected: %v", event.Err)
+		}
+	}
+}
@@ -2,12 +2,15 @@ module github.com/lrosenman/ambient
 
 go 1.19
 
+require (
+	github.com/go-faker/faker/v4 v4.0.0-beta.4
+	github.com/stretchr/testify v1.8.1
+)
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/go-faker/faker/v4 v4.0.0-beta.4 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.5.0 // indirect
-	github.com/stretchr/testify v1.8.1 // indirect
 	golang.org/x/text v0.5.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,321 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+// Package aggregate resamples the records of a station into hourly,
+// daily or monthly summaries, aligned to the time zone of the station.
+//
+// Each numeric field is reduced the way its quantity calls for: the
+// mean for temperatures and most measurements, the vector mean for
+// wind directions, the maximum for gusts and the last value for the
+// cumulative totals, such as the rain counters.  The rain fallen in
+// each window is the sum of the increments of the rain counters,
+// reconciled by the rain package.
+package aggregate
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+	"github.com/lrosenman/ambient/pkg/rain"
+	"github.com/lrosenman/ambient/pkg/units"
+)
+
+// DefaultInterval is the interval between the records of the API.
+const DefaultInterval = 5 * time.Minute
+
+// Period is the length of the windows records are resampled into.
+type Period int
+
+const (
+	// Hourly windows start on the hour.
+	Hourly Period = iota
+	// Daily windows start at local midnight.
+	Daily
+	// Monthly windows start at local midnight on the first of the month.
+	Monthly
+)
+
+func (p Period) String() string {
+	switch p {
+	case Hourly:
+		return "hourly"
+	case Daily:
+		return "daily"
+	case Monthly:
+		return "monthly"
+	}
+	return "unknown"
+}
+
+// start returns the start of the window of p holding t, in loc.
+func (p Period) start(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch p {
+	case Hourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case Daily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+}
+
+// next returns the start of the window of p following the one
+// starting at start.
+func (p Period) next(start time.Time) time.Time {
+	switch p {
+	case Hourly:
+		return start.Add(time.Hour)
+	case Daily:
+		return start.AddDate(0, 0, 1)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// Reducer is how the values of a field within a window
+// are reduced to the Value of its Stat.
+type Reducer int
+
+const (
+	// Mean is the arithmetic mean.
+	Mean Reducer = iota
+	// VectorMean is the mean direction, in degrees.
+	VectorMean
+	// Max is the highest value.
+	Max
+	// Last is the latest value.
+	Last
+)
+
+func (r Reducer) String() string {
+	switch r {
+	case Mean:
+		return "mean"
+	case VectorMean:
+		return "vector mean"
+	case Max:
+		return "max"
+	case Last:
+		return "last"
+	}
+	return "unknown"
+}
+
+// reducers are the reducers of the fields that are
+// not chosen from the unit of the field.
+var reducers = map[string]Reducer{
+	"windgustmph":        Max,
+	"maxdailygust":       Max,
+	"hourlyrainin":       Max,
+	"lightning_distance": Last,
+}
+
+// ReducerFor returns the Reducer of field: VectorMean for directions,
+// Max for gusts and the rain rate, Last for counters, states and
+// values already averaged over 24 hours, and Mean for the others.
+func ReducerFor(field ambient.Field) Reducer {
+	if reducer, ok := reducers[field.Key]; ok {
+		return reducer
+	}
+	switch {
+	case field.Unit == units.Degree:
+		return VectorMean
+	case field.Unit == units.Inch, field.Unit == units.Strikes, field.Unit == units.Flag,
+		strings.HasSuffix(field.Key, "_24h"):
+		return Last
+	}
+	return Mean
+}
+
+// Stat is the summary of a field over a window.
+type Stat struct {
+	Field   ambient.Field
+	Reducer Reducer
+	// Value is the value of the field reduced by Reducer.
+	Value float64
+	// Min, Max and Sum are those of the Count values
+	// of the field within the window.
+	Min, Max, Sum float64
+	Count         int
+
+	sin, cos float64
+}
+
+// Mean returns the arithmetic mean of the values of the field.
+func (s Stat) Mean() float64 {
+	return s.Sum / float64(s.Count)
+}
+
+func (s *Stat) add(value float64) {
+	if s.Count == 0 || value < s.Min {
+		s.Min = value
+	}
+	if s.Count == 0 || value > s.Max {
+		s.Max = value
+	}
+	s.Count++
+	s.Sum += value
+	s.Value = value
+	if s.Reducer == VectorMean {
+		s.sin += math.Sin(value * math.Pi / 180)
+		s.cos += math.Cos(value * math.Pi / 180)
+	}
+}
+
+func (s *Stat) reduce() {
+	switch s.Reducer {
+	case Mean:
+		s.Value = s.Mean()
+	case VectorMean:
+		s.Value = math.Mod(math.Atan2(s.sin, s.cos)*180/math.Pi+360, 360)
+	case Max:
+		s.Value = s.Max
+	}
+}
+
+// Summary is the summary of the records of a window.
+type Summary struct {
+	// Start and End bound the window, End excluded, in
+	// the time zone of the station.
+	Start, End time.Time
+	// Records is the number of records in the window.
+	Records int
+	// Expected is the number of intervals in the window and Missing
+	// the number of those without any record.
+	Expected, Missing int
+	// Coverage is the fraction of the intervals with a record.
+	Coverage float64
+	// Rain is the rain fallen during the window, in inches, the
+	// sum of the rain.Increments ending within it.
+	Rain float64
+	// Fields holds the summary of each numeric field
+	// held by a record of the window, by API name.
+	Fields map[string]Stat
+}
+
+// Value returns the reduced value of field, given by its API name such
+// as "tempf", and whether a record of the window holds it.
+func (s Summary) Value(field string) (float64, bool) {
+	f, ok := ambient.LookupField(field)
+	if !ok {
+		return 0, false
+	}
+	stat, ok := s.Fields[f.Key]
+	return stat.Value, ok
+}
+
+type config struct {
+	loc      *time.Location
+	interval time.Duration
+}
+
+// Option configures Resample.
+type Option func(*config)
+
+// WithLocation aligns the windows to loc instead
+// of the TZ reported by the records.
+func WithLocation(loc *time.Location) Option {
+	return func(c *config) {
+		c.loc = loc
+	}
+}
+
+// WithInterval sets the interval expected between records,
+// DefaultInterval by default, on which Coverage is based.
+func WithInterval(interval time.Duration) Option {
+	return func(c *config) {
+		c.interval = interval
+	}
+}
+
+// Resample summarizes records into windows of period, in chronological
+// order, from the window of the first record to that of the last,
+// including the windows without records.  Records may be in any order,
+// such as the newest first order of DeviceMac, and duplicates are
+// ignored.  The windows are aligned to the TZ of the records, UTC when
+// they report none.
+func Resample(records []ambient.Record, period Period, opts ...Option) []Summary {
+	c := config{interval: DefaultInterval}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	records = rain.Sorted(records)
+	if len(records) == 0 {
+		return nil
+	}
+	if c.loc == nil {
+		c.loc = location(records)
+	}
+
+	increments := rain.Increments(records)
+	var summaries []Summary
+	last := records[len(records)-1].Date
+	i, k := 0, 0
+	for start := period.start(records[0].Date, c.loc); !start.After(last); start = period.next(start) {
+		end := period.next(start)
+		j := i
+		for j < len(records) && records[j].Date.Before(end) {
+			j++
+		}
+		l := k
+		for l < len(increments) && increments[l].End.Before(end) {
+			l++
+		}
+		summaries = append(summaries, summarize(start, end, records[i:j], increments[k:l], c.interval))
+		i, k = j, l
+	}
+	return summaries
+}
+
+// summarize returns the summary of the records, and the rain
+// increments ending with them, of the window from start to end.
+func summarize(start, end time.Time, records []ambient.Record, increments []rain.Increment, interval time.Duration) Summary {
+	s := Summary{Start: start, End: end, Records: len(records), Fields: make(map[string]Stat)}
+	s.Expected = int((end.Sub(start) + interval - 1) / interval)
+	slots := make(map[int]bool)
+	fields := ambient.Fields()
+	s.Rain = rain.Total(increments)
+	for _, record := range records {
+		slots[int(record.Date.Sub(start)/interval)] = true
+		for _, field := range fields {
+			value, ok := record.Number(field.Key)
+			if !ok {
+				continue
+			}
+			stat, ok := s.Fields[field.Key]
+			if !ok {
+				stat = Stat{Field: field, Reducer: ReducerFor(field)}
+			}
+			stat.add(value)
+			s.Fields[field.Key] = stat
+		}
+	}
+	for key, stat := range s.Fields {
+		stat.reduce()
+		s.Fields[key] = stat
+	}
+	s.Missing = s.Expected - len(slots)
+	if s.Missing < 0 {
+		s.Missing = 0
+	}
+	if s.Expected > 0 {
+		s.Coverage = float64(s.Expected-s.Missing) / float64(s.Expected)
+	}
+	return s
+}
+
+// location returns the time zone reported by records, UTC if none.
+func location(records []ambient.Record) *time.Location {
+	for _, record := range records {
+		if record.TZ == "" {
+			continue
+		}
+		if loc, err := time.LoadLocation(record.TZ); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
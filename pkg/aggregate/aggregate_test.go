@@ -0,0 +1,177 @@
+package aggregate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+	"github.com/stretchr/testify/require"
+)
+
+func chicago(t *testing.T) *time.Location {
+	loc, err := time.LoadLocation("America/Chicago")
+	require.Nil(t, err)
+	return loc
+}
+
+// record returns fields dated date at a station in Chicago, holding
+// the fields present as well as its non-zero ones.
+func record(t *testing.T, date time.Time, fields ambient.Record, present ...string) ambient.Record {
+	fields.Date, fields.TZ = date.UTC(), "America/Chicago"
+	require.Nil(t, fields.SetPresent(present...))
+	return fields
+}
+
+// reversed returns records newest first, like DeviceMac.
+func reversed(records []ambient.Record) []ambient.Record {
+	result := make([]ambient.Record, len(records))
+	for i, record := range records {
+		result[len(records)-1-i] = record
+	}
+	return result
+}
+
+func Test_Resample_Hourly(t *testing.T) {
+	loc := chicago(t)
+	start := time.Date(2023, time.June, 1, 10, 0, 0, 0, loc)
+	var records []ambient.Record
+	for i := 0; i < 24; i++ {
+		direction := 350
+		if i%2 == 1 {
+			direction = 10
+		}
+		fields := ambient.Record{
+			Tempf: float64(60 + i), Winddir: direction, Windgustmph: float64(i % 7),
+			Maxdailygust: float64(20 + i), Dailyrainin: float64(i) * 0.01, Humidity: 50,
+		}
+		records = append(records, record(t, start.Add(time.Duration(i)*5*time.Minute), fields, "windgustmph", "dailyrainin"))
+	}
+
+	summaries := Resample(reversed(records), Hourly)
+	require.Len(t, summaries, 2)
+
+	first := summaries[0]
+	require.Equal(t, start, first.Start)
+	require.Equal(t, start.Add(time.Hour), first.End)
+	require.Equal(t, loc, first.Start.Location())
+	require.Equal(t, 12, first.Records)
+	require.Equal(t, 12, first.Expected)
+	require.Equal(t, 0, first.Missing)
+	require.Equal(t, 1.0, first.Coverage)
+
+	temp, ok := first.Value("tempf")
+	require.True(t, ok)
+	require.Equal(t, 65.5, temp)
+	stat := first.Fields["tempf"]
+	require.Equal(t, Mean, stat.Reducer)
+	require.Equal(t, 60.0, stat.Min)
+	require.Equal(t, 71.0, stat.Max)
+	require.Equal(t, 12, stat.Count)
+
+	direction, _ := first.Value("winddir")
+	require.InDelta(t, 0, direction, 1e-9)
+	gust, _ := first.Value("windgustmph")
+	require.Equal(t, 6.0, gust)
+	maxGust, _ := first.Value("maxdailygust")
+	require.Equal(t, 31.0, maxGust)
+	daily, _ := first.Value("dailyrainin")
+	require.InDelta(t, 0.11, daily, 1e-9)
+	require.InDelta(t, 0.11, first.Rain, 1e-9)
+	require.InDelta(t, 0.12, summaries[1].Rain, 1e-9)
+
+	_, ok = first.Value("uv")
+	require.False(t, ok)
+}
+
+func Test_Resample_Missing(t *testing.T) {
+	loc := chicago(t)
+	start := time.Date(2023, time.June, 1, 10, 0, 0, 0, loc)
+	var records []ambient.Record
+	for i := 0; i < 36; i++ {
+		// A gap in the first hour and none in the second.
+		if i == 3 || i == 4 || (i >= 12 && i < 24) {
+			continue
+		}
+		records = append(records, record(t, start.Add(time.Duration(i)*5*time.Minute), ambient.Record{Tempf: 70}))
+	}
+	// A duplicate is ignored.
+	records = append(records, records[0])
+
+	summaries := Resample(records, Hourly)
+	require.Len(t, summaries, 3)
+	require.Equal(t, 10, summaries[0].Records)
+	require.Equal(t, 2, summaries[0].Missing)
+	require.InDelta(t, 10.0/12, summaries[0].Coverage, 1e-9)
+	require.Equal(t, 0, summaries[1].Records)
+	require.Equal(t, 12, summaries[1].Missing)
+	require.Equal(t, 0.0, summaries[1].Coverage)
+	require.Empty(t, summaries[1].Fields)
+	require.Equal(t, 1.0, summaries[2].Coverage)
+}
+
+func Test_Resample_Daily(t *testing.T) {
+	loc := chicago(t)
+	// Daylight saving time ends on November 5.
+	start := time.Date(2023, time.November, 4, 22, 0, 0, 0, loc)
+	var records []ambient.Record
+	for i := 0; i < 8; i++ {
+		date := start.Add(time.Duration(i) * time.Hour)
+		daily := 0.2
+		if i >= 2 {
+			daily = 0.1 * float64(i-1)
+		}
+		records = append(records, record(t, date, ambient.Record{Dailyrainin: daily, Totalrainin: 10 + 0.1*float64(i)}))
+	}
+
+	summaries := Resample(records, Daily, WithInterval(time.Hour))
+	require.Len(t, summaries, 2)
+	require.Equal(t, time.Date(2023, time.November, 4, 0, 0, 0, 0, loc), summaries[0].Start)
+	require.Equal(t, time.Date(2023, time.November, 5, 0, 0, 0, 0, loc), summaries[1].Start)
+	require.Equal(t, 24, summaries[0].Expected)
+	require.Equal(t, 25, summaries[1].Expected)
+	// Rain comes from Totalrainin, not the reset of Dailyrainin.
+	require.InDelta(t, 0.1, summaries[0].Rain, 1e-9)
+	require.InDelta(t, 0.6, summaries[1].Rain, 1e-9)
+}
+
+func Test_Resample_Monthly(t *testing.T) {
+	records := []ambient.Record{
+		record(t, time.Date(2023, time.January, 31, 12, 0, 0, 0, time.UTC), ambient.Record{Dailyrainin: 0.5}),
+		record(t, time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC), ambient.Record{Dailyrainin: 0.25}),
+	}
+
+	summaries := Resample(records, Monthly, WithLocation(time.UTC))
+	require.Len(t, summaries, 3)
+	require.Equal(t, time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC), summaries[1].Start)
+	require.Equal(t, 28*24*12, summaries[1].Expected)
+	// Dailyrainin went down: it was reset.
+	require.Equal(t, 0.25, summaries[2].Rain)
+}
+
+func Test_Resample_Empty(t *testing.T) {
+	require.Nil(t, Resample(nil, Daily))
+}
+
+func Test_ReducerFor(t *testing.T) {
+	tests := map[string]Reducer{
+		"tempf":          Mean,
+		"humidity":       Mean,
+		"winddir":        VectorMean,
+		"winddir_avg10m": VectorMean,
+		"windgustmph":    Max,
+		"maxdailygust":   Max,
+		"hourlyrainin":   Max,
+		"dailyrainin":    Last,
+		"totalrainin":    Last,
+		"lightning_day":  Last,
+		"batt1":          Last,
+		"pm25_24h":       Last,
+		"pm25":           Mean,
+	}
+
+	for key, expected := range tests {
+		field, ok := ambient.LookupField(key)
+		require.True(t, ok, key)
+		require.Equal(t, expected, ReducerFor(field), key)
+	}
+}
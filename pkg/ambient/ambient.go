@@ -10,27 +10,25 @@
 package ambient
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
-	"net/http"
-	"net/url"
-	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // APIVer is the current version of the API.
 const APIVer = "v1"
 
-// APIEP is the endpoint to be called.
+// APIEP is the default endpoint to be called.
 const APIEP = "https://api.ambientweather.net/" + APIVer
 
-var httpGet = http.Get
-
 // Record maps the data for a specific time
 // as returned by the API.
 //
+// A field missing from the API response is left zero; Has tells
+// whether the response held it.
+//
 //goland:noinspection GoSnakeCaseUsage
 type Record struct {
 	Date               time.Time
@@ -163,6 +161,31 @@ type Record struct {
 	TZ                string
 	Aqi_pm25_in       int
 	Aqi_pm25_in_24h   int
+	Aqi_pm25          int         `json:"aqi_pm25"`
+	Aqi_pm25_24h      int         `json:"aqi_pm25_24h"`
+	Pm25_in           float64     `json:"pm25_in"`
+	Pm25_in_24h       float64     `json:"pm25_in_24h"`
+	Co2_in            float64     `json:"co2_in"`
+	Co2_in_24h        float64     `json:"co2_in_24h"`
+	Leak1             int         `json:"leak1"`
+	Leak2             int         `json:"leak2"`
+	Leak3             int         `json:"leak3"`
+	Leak4             int         `json:"leak4"`
+	Batt_leak1        json.Number `json:"batt_leak1"`
+	Batt_leak2        json.Number `json:"batt_leak2"`
+	Batt_leak3        json.Number `json:"batt_leak3"`
+	Batt_leak4        json.Number `json:"batt_leak4"`
+	Batt_25           json.Number `json:"batt_25"`
+	Batt_25in         json.Number `json:"batt_25in"`
+	Batt_cellgateway  json.Number `json:"batt_cellgateway"`
+	// Dateutc is Date in milliseconds since the Unix epoch.
+	Dateutc int64 `json:"dateutc"`
+
+	// Extra holds the fields of the response that Record
+	// does not model, such as those of new sensors.
+	Extra map[string]json.RawMessage `json:"-" faker:"-"`
+
+	present presence
 }
 
 // DeviceInfo maps the info portion of the /devices API.
@@ -236,6 +259,27 @@ func (Key *Key) ApplicationKey() string {
 	return Key.applicationKey
 }
 
+// String returns a representation of Key with both keys masked,
+// so that it can safely be logged.
+func (key Key) String() string {
+	return "Key{applicationKey: " + maskSecret(key.applicationKey) +
+		", apiKey: " + maskSecret(key.apiKey) + "}"
+}
+
+// GoString implements fmt.GoStringer, masking both keys for %#v.
+func (key Key) GoString() string {
+	return "ambient.Key{applicationKey:" + strconv.Quote(maskSecret(key.applicationKey)) +
+		", apiKey:" + strconv.Quote(maskSecret(key.apiKey)) + "}"
+}
+
+// maskSecret hides all but the last 4 characters of secret.
+func maskSecret(secret string) string {
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
+	}
+	return strings.Repeat("*", len(secret)-4) + secret[len(secret)-4:]
+}
+
 // SetApplicationKey sets the applicationKey
 func (Key *Key) SetApplicationKey(applicationKey string) {
 	Key.applicationKey = applicationKey
@@ -246,146 +290,24 @@ func (Key *Key) SetAPIKey(apiKey string) {
 	Key.apiKey = apiKey
 }
 
-// Device issues a /devices call.
+// Device issues a /devices call using DefaultClient.
 func Device(key Key) (APIDeviceResponse, error) {
-	var ar APIDeviceResponse
+	return DefaultClient.Device(key)
+}
 
-	apiurl := APIEP + "/devices?applicationKey=" + key.applicationKey +
-		"&apiKey=" + key.apiKey
-	startTime := time.Now()
-	resp, err := httpGet(apiurl)
-	ar.ResponseTime = time.Since(startTime)
-	if err != nil {
-		return ar, err
-	}
-	ar.HTTPResponseCode = resp.StatusCode
-	ar.JSONResponse, err = io.ReadAll(resp.Body)
-	if err != nil {
-		return ar, err
-	}
-	switch resp.StatusCode {
-	case 200:
-	case 429, 502, 503:
-		{
-			if resp.StatusCode >= 500 {
-				ar.JSONResponse, _ = json.Marshal(
-					fmt.Sprintf(
-						"{\"errormessage\": \"HTTP Error Code: %d\"}", resp.StatusCode,
-					),
-				)
-			}
-			return ar, nil
-		}
-	default:
-		{
-			_, err := fmt.Fprintf(
-				os.Stderr, "ambient.Device: HTTPResponseCode=%d\nFull Response:\n%+v",
-				resp.StatusCode, resp,
-			)
-			if err != nil {
-				return APIDeviceResponse{}, err
-			}
-			return ar, errors.New(
-				"bad non-200/429/502/503 Response Code",
-			)
-		}
-	}
-	err = json.Unmarshal(ar.JSONResponse, &ar.DeviceRecord)
-	if err != nil {
-		return ar, err
-	}
-	var DeviceInterface interface{}
-	err = json.Unmarshal(ar.JSONResponse, &DeviceInterface)
-	if err != nil {
-		return ar, err
-	}
-	DeviceMap := DeviceInterface.([]interface{})
-	for key, value := range DeviceMap {
-		switch value2 := value.(type) {
-		case map[string]interface{}:
-			for k1, v1 := range value2 {
-				if k1 == "lastData" {
-					switch newkey := v1.(type) {
-					case map[string]interface{}:
-						LDF := make(map[string]interface{})
-						for k2, v2 := range newkey {
-							LDF[k2] = v2
-						}
-						ar.DeviceRecord[key].LastDataFields = LDF
-					}
-				}
-			}
-		}
-	}
-	return ar, nil
+// DeviceContext issues a /devices call bound to ctx using DefaultClient.
+func DeviceContext(ctx context.Context, key Key) (APIDeviceResponse, error) {
+	return DefaultClient.DeviceContext(ctx, key)
 }
 
-// DeviceMac issues a /devices/macaddr call.
+// DeviceMac issues a /devices/macaddr call using DefaultClient.
 func DeviceMac(key Key, macaddr string, endtime time.Time, limit int64) (APIDeviceMacResponse, error) {
-	var ar APIDeviceMacResponse
-	apiurl := APIEP + "/devices/" + macaddr + "?endDate=" + url.QueryEscape(endtime.Format(time.RFC3339)) +
-		"&limit=" + fmt.Sprintf("%d", limit) + "&applicationKey=" + key.applicationKey +
-		"&apiKey=" + key.apiKey
-	startTime := time.Now()
-	resp, err := httpGet(apiurl)
-	ar.ResponseTime = time.Since(startTime)
-	if err != nil {
-		return ar, err
-	}
-	ar.HTTPResponseCode = resp.StatusCode
-	ar.JSONResponse, err = io.ReadAll(resp.Body)
-	if err != nil {
-		return ar, err
-	}
-	switch resp.StatusCode {
-	case 200:
-	case 429, 502, 503:
-		{
-			if resp.StatusCode >= 500 {
-				if resp.StatusCode >= 500 {
-					ar.JSONResponse, _ = json.Marshal(
-						fmt.Sprintf(
-							"{\"errormessage\": \"HTTP Error Code: %d\"}", resp.StatusCode,
-						),
-					)
-				}
-			}
-			return ar, nil
-		}
-	default:
-		{
-			_, err := fmt.Fprintf(
-				os.Stderr,
-				"ambient.DeviceMac: HTTPResponseCode=%d\n"+
-					"Full Response:\n%+v",
-				resp.StatusCode, resp,
-			)
-			if err != nil {
-				return APIDeviceMacResponse{}, err
-			}
-			return ar, errors.New("bad non-200/429/502/503 Response Code")
-		}
-	}
-	err = json.Unmarshal(ar.JSONResponse, &ar.Record)
-	if err != nil {
-		return ar, err
-	}
-	var DeviceInterface interface{}
-	err = json.Unmarshal(ar.JSONResponse, &DeviceInterface)
-	if err != nil {
-		return ar, err
-	}
-	DeviceMap := DeviceInterface.([]interface{})
-	RDF := make([]map[string]interface{}, len(DeviceMap))
-	for key, value := range DeviceMap {
-		RDF[key] = make(map[string]interface{})
-		switch value2 := value.(type) {
-		case map[string]interface{}:
-			for k2, v2 := range value2 {
-				RDF[key][k2] = v2
-			}
-		}
-	}
-	ar.RecordFields = RDF
-	return ar, nil
+	return DefaultClient.DeviceMac(key, macaddr, endtime, limit)
+}
+
+// DeviceMacContext issues a /devices/macaddr call bound to ctx using DefaultClient.
+func DeviceMacContext(
+	ctx context.Context, key Key, macaddr string, endtime time.Time, limit int64,
+) (APIDeviceMacResponse, error) {
+	return DefaultClient.DeviceMacContext(ctx, key, macaddr, endtime, limit)
 }
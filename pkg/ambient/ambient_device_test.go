@@ -1,6 +1,7 @@
 package ambient
 
 import (
+	"errors"
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"testing"
@@ -13,10 +14,10 @@ func Test_Device_OkResponse_ReturnsListOfDevices(t *testing.T) {
 		getValidDeviceRecord(),
 	}
 
-	httpGet = getMockHttpGetRequest(http.StatusOK, expectedResult)
+	client := getMockClient(http.StatusOK, expectedResult)
 
 	key := NewKey("application-key", "api-key")
-	devices, err := Device(key)
+	devices, err := client.Device(key)
 
 	require.Nil(t, err)
 
@@ -26,72 +27,69 @@ func Test_Device_OkResponse_ReturnsListOfDevices(t *testing.T) {
 	requireDeviceRecordsEqualValues(t, expectedResult, devices.DeviceRecord)
 }
 
-func Test_Device_TooManyRequestsResponse_ReturnsNoData(t *testing.T) {
+func Test_Device_TooManyRequestsResponse_ReturnsErrRateLimited(t *testing.T) {
 	expectedResult := []*DeviceRecord{
 		getValidDeviceRecord(),
 		getValidDeviceRecord(),
 	}
 
-	httpGet = getMockHttpGetRequest(http.StatusTooManyRequests, expectedResult)
+	client := getMockClient(http.StatusTooManyRequests, expectedResult)
 
 	key := NewKey("application-key", "api-key")
-	devices, err := Device(key)
+	devices, err := client.Device(key)
 
-	require.Nil(t, err)
+	require.True(t, errors.Is(err, ErrRateLimited))
 
 	require.Equal(t, http.StatusTooManyRequests, devices.HTTPResponseCode)
 	require.Empty(t, devices.DeviceRecord)
-	require.NotContains(t, string(devices.JSONResponse), "errormessage")
 }
 
-func Test_Device_ServiceUnavailableResponse_ReturnsNoDataWithErrorBody(t *testing.T) {
+func Test_Device_ServiceUnavailableResponse_ReturnsErrServerUnavailable(t *testing.T) {
 	expectedResult := []*DeviceRecord{
 		getValidDeviceRecord(),
 		getValidDeviceRecord(),
 	}
 
-	httpGet = getMockHttpGetRequest(http.StatusServiceUnavailable, expectedResult)
+	client := getMockClient(http.StatusServiceUnavailable, expectedResult)
 
 	key := NewKey("application-key", "api-key")
-	devices, err := Device(key)
+	devices, err := client.Device(key)
 
-	require.Nil(t, err)
+	require.True(t, errors.Is(err, ErrServerUnavailable))
 
 	require.Equal(t, http.StatusServiceUnavailable, devices.HTTPResponseCode)
 	require.Empty(t, devices.DeviceRecord)
-	require.Contains(t, string(devices.JSONResponse), "errormessage")
 }
 
-func Test_Device_BadGatewayResponse_ReturnsNoDataNoDataWithErrorBody(t *testing.T) {
+func Test_Device_BadGatewayResponse_ReturnsErrServerUnavailable(t *testing.T) {
 	expectedResult := []*DeviceRecord{
 		getValidDeviceRecord(),
 		getValidDeviceRecord(),
 	}
 
-	httpGet = getMockHttpGetRequest(http.StatusBadGateway, expectedResult)
+	client := getMockClient(http.StatusBadGateway, expectedResult)
 
 	key := NewKey("application-key", "api-key")
-	devices, err := Device(key)
+	devices, err := client.Device(key)
 
-	require.Nil(t, err)
+	require.True(t, errors.Is(err, ErrServerUnavailable))
 
 	require.Equal(t, http.StatusBadGateway, devices.HTTPResponseCode)
 	require.Empty(t, devices.DeviceRecord)
-	require.Contains(t, string(devices.JSONResponse), "errormessage")
 }
 
-func Test_Device_NonSupportedResponse_ReturnsError(t *testing.T) {
+func Test_Device_ForbiddenResponse_ReturnsErrUnauthorized(t *testing.T) {
 	expectedResult := []*DeviceRecord{
 		getValidDeviceRecord(),
 		getValidDeviceRecord(),
 	}
 
-	httpGet = getMockHttpGetRequest(http.StatusForbidden, expectedResult)
+	client := getMockClient(http.StatusForbidden, expectedResult)
 
 	key := NewKey("application-key", "api-key")
-	devices, err := Device(key)
+	devices, err := client.Device(key)
 
-	require.NotNil(t, err)
+	require.True(t, errors.Is(err, ErrUnauthorized))
 
 	require.Equal(t, http.StatusForbidden, devices.HTTPResponseCode)
 	require.Empty(t, devices.DeviceRecord)
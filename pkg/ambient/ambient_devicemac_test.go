@@ -1,6 +1,7 @@
 package ambient
 
 import (
+	"errors"
 	"github.com/go-faker/faker/v4"
 	"github.com/stretchr/testify/require"
 	"net/http"
@@ -15,9 +16,9 @@ func Test_DeviceMac_OkResponse_ReturnsQueryResults(t *testing.T) {
 	resultLimit := 15
 	expectedResult := getValidRecordSlice(resultLimit)
 
-	httpGet = getMockHttpGetRequest(http.StatusOK, expectedResult)
+	client := getMockClient(http.StatusOK, expectedResult)
 
-	queryResults, err := DeviceMac(key, deviceMac, endTime, int64(resultLimit))
+	queryResults, err := client.DeviceMac(key, deviceMac, endTime, int64(resultLimit))
 
 	require.Nil(t, err)
 
@@ -27,74 +28,72 @@ func Test_DeviceMac_OkResponse_ReturnsQueryResults(t *testing.T) {
 	requireRecordsEqualValues(t, expectedResult, queryResults.Record)
 }
 
-func Test_DeviceMac_TooManyRequestsResponse_ReturnsNoData(t *testing.T) {
+func Test_DeviceMac_TooManyRequestsResponse_ReturnsErrRateLimited(t *testing.T) {
 	key := NewKey("application-key", "api-key")
 	deviceMac := faker.MacAddress()
 	endTime := time.Now().Add(time.Minute * -1)
 	resultLimit := 15
 	expectedResult := getValidRecordSlice(resultLimit)
 
-	httpGet = getMockHttpGetRequest(http.StatusTooManyRequests, expectedResult)
+	client := getMockClient(http.StatusTooManyRequests, expectedResult)
 
-	queryResults, err := DeviceMac(key, deviceMac, endTime, int64(resultLimit))
+	queryResults, err := client.DeviceMac(key, deviceMac, endTime, int64(resultLimit))
 
-	require.Nil(t, err)
+	require.True(t, errors.Is(err, ErrRateLimited))
 
 	require.Equal(t, http.StatusTooManyRequests, queryResults.HTTPResponseCode)
 	require.NotNil(t, queryResults.JSONResponse)
 	require.Empty(t, queryResults.Record)
 }
 
-func Test_DeviceMac_ServiceUnavailableResponse_ReturnsNoDataAndErrorMessage(t *testing.T) {
+func Test_DeviceMac_ServiceUnavailableResponse_ReturnsErrServerUnavailable(t *testing.T) {
 	key := NewKey("application-key", "api-key")
 	deviceMac := faker.MacAddress()
 	endTime := time.Now().Add(time.Minute * -1)
 	resultLimit := 15
 	expectedResult := getValidRecordSlice(resultLimit)
 
-	httpGet = getMockHttpGetRequest(http.StatusServiceUnavailable, expectedResult)
+	client := getMockClient(http.StatusServiceUnavailable, expectedResult)
 
-	queryResults, err := DeviceMac(key, deviceMac, endTime, int64(resultLimit))
+	queryResults, err := client.DeviceMac(key, deviceMac, endTime, int64(resultLimit))
 
-	require.Nil(t, err)
+	require.True(t, errors.Is(err, ErrServerUnavailable))
 
 	require.Equal(t, http.StatusServiceUnavailable, queryResults.HTTPResponseCode)
 	require.NotNil(t, queryResults.JSONResponse)
 	require.Empty(t, queryResults.Record)
-	require.Contains(t, string(queryResults.JSONResponse), "errormessage")
 }
 
-func Test_DeviceMac_BadGatewayResponse_ReturnsNoDataAndErrorMessage(t *testing.T) {
+func Test_DeviceMac_BadGatewayResponse_ReturnsErrServerUnavailable(t *testing.T) {
 	key := NewKey("application-key", "api-key")
 	deviceMac := faker.MacAddress()
 	endTime := time.Now().Add(time.Minute * -1)
 	resultLimit := 15
 	expectedResult := getValidRecordSlice(resultLimit)
 
-	httpGet = getMockHttpGetRequest(http.StatusBadGateway, expectedResult)
+	client := getMockClient(http.StatusBadGateway, expectedResult)
 
-	queryResults, err := DeviceMac(key, deviceMac, endTime, int64(resultLimit))
+	queryResults, err := client.DeviceMac(key, deviceMac, endTime, int64(resultLimit))
 
-	require.Nil(t, err)
+	require.True(t, errors.Is(err, ErrServerUnavailable))
 
 	require.Equal(t, http.StatusBadGateway, queryResults.HTTPResponseCode)
 	require.NotNil(t, queryResults.JSONResponse)
 	require.Empty(t, queryResults.Record)
-	require.Contains(t, string(queryResults.JSONResponse), "errormessage")
 }
 
-func Test_DeviceMac_NonSupportedResponse_ReturnsError(t *testing.T) {
+func Test_DeviceMac_ForbiddenResponse_ReturnsErrUnauthorized(t *testing.T) {
 	key := NewKey("application-key", "api-key")
 	deviceMac := faker.MacAddress()
 	endTime := time.Now().Add(time.Minute * -1)
 	resultLimit := 15
 	expectedResult := getValidRecordSlice(resultLimit)
 
-	httpGet = getMockHttpGetRequest(http.StatusForbidden, expectedResult)
+	client := getMockClient(http.StatusForbidden, expectedResult)
 
-	queryResults, err := DeviceMac(key, deviceMac, endTime, int64(resultLimit))
+	queryResults, err := client.DeviceMac(key, deviceMac, endTime, int64(resultLimit))
 
-	require.NotNil(t, err)
+	require.True(t, errors.Is(err, ErrUnauthorized))
 
 	require.Equal(t, http.StatusForbidden, queryResults.HTTPResponseCode)
 	require.Empty(t, queryResults.Record)
@@ -1,6 +1,7 @@
 package ambient
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -30,3 +31,21 @@ func Test_SetAPIKey_SetsValue(t *testing.T) {
 	require.Equal(t, "application-key", key.ApplicationKey())
 	require.Equal(t, "something else", key.APIKey())
 }
+
+func Test_Key_String_MasksKeys(t *testing.T) {
+	key := NewKey("21a439e927a84a25bb79ffe894fdd372", "78f9704baaab411a87edeed59052cbb6")
+
+	for _, format := range []string{"%s", "%v", "%+v", "%#v"} {
+		formatted := fmt.Sprintf(format, key)
+		require.NotContains(t, formatted, "21a439e927a84a25", format)
+		require.NotContains(t, formatted, "78f9704baaab411a", format)
+		require.Contains(t, formatted, "d372", format)
+		require.Contains(t, formatted, "cbb6", format)
+	}
+}
+
+func Test_Key_String_MasksShortKeysEntirely(t *testing.T) {
+	key := NewKey("app", "api-key")
+
+	require.Equal(t, "Key{applicationKey: ***, apiKey: *******}", key.String())
+}
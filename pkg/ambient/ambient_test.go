@@ -62,16 +62,31 @@ func overrideFakeBatteryData(data *Record) {
 	data.Batt8 = "12"
 	data.Batt9 = "13"
 	data.Batt10 = "14"
+	data.Batt_leak1 = "15"
+	data.Batt_leak2 = "16"
+	data.Batt_leak3 = "17"
+	data.Batt_leak4 = "18"
+	data.Batt_25 = "19"
+	data.Batt_25in = "20"
+	data.Batt_cellgateway = "21"
 }
 
-func getMockHttpGetRequest(statusCode int, responseData interface{}) func(url string) (*http.Response, error) {
-	return func(url string) (*http.Response, error) {
-		response := &http.Response{}
-		response.StatusCode = statusCode
-		response.Body = io.NopCloser(strings.NewReader(mapToJson(responseData)))
+type roundTripFunc func(req *http.Request) (*http.Response, error)
 
-		return response, nil
-	}
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func getMockClient(statusCode int, responseData interface{}) *Client {
+	return NewClient(WithHTTPClient(&http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			response := &http.Response{}
+			response.StatusCode = statusCode
+			response.Body = io.NopCloser(strings.NewReader(mapToJson(responseData)))
+
+			return response, nil
+		}),
+	}))
 }
 
 func mapToJson(toMap interface{}) string {
@@ -268,6 +283,24 @@ func requireRecordEqualValues(t *testing.T, expected Record, actual Record) {
 	require.EqualValues(t, expected.TZ, actual.TZ)
 	require.EqualValues(t, expected.Aqi_pm25_in, actual.Aqi_pm25_in)
 	require.EqualValues(t, expected.Aqi_pm25_in_24h, actual.Aqi_pm25_in_24h)
+	require.EqualValues(t, expected.Aqi_pm25, actual.Aqi_pm25)
+	require.EqualValues(t, expected.Aqi_pm25_24h, actual.Aqi_pm25_24h)
+	require.EqualValues(t, expected.Pm25_in, actual.Pm25_in)
+	require.EqualValues(t, expected.Pm25_in_24h, actual.Pm25_in_24h)
+	require.EqualValues(t, expected.Co2_in, actual.Co2_in)
+	require.EqualValues(t, expected.Co2_in_24h, actual.Co2_in_24h)
+	require.EqualValues(t, expected.Leak1, actual.Leak1)
+	require.EqualValues(t, expected.Leak2, actual.Leak2)
+	require.EqualValues(t, expected.Leak3, actual.Leak3)
+	require.EqualValues(t, expected.Leak4, actual.Leak4)
+	require.EqualValues(t, toFloat64(expected.Batt_leak1), toFloat64(actual.Batt_leak1))
+	require.EqualValues(t, toFloat64(expected.Batt_leak2), toFloat64(actual.Batt_leak2))
+	require.EqualValues(t, toFloat64(expected.Batt_leak3), toFloat64(actual.Batt_leak3))
+	require.EqualValues(t, toFloat64(expected.Batt_leak4), toFloat64(actual.Batt_leak4))
+	require.EqualValues(t, toFloat64(expected.Batt_25), toFloat64(actual.Batt_25))
+	require.EqualValues(t, toFloat64(expected.Batt_25in), toFloat64(actual.Batt_25in))
+	require.EqualValues(t, toFloat64(expected.Batt_cellgateway), toFloat64(actual.Batt_cellgateway))
+	require.EqualValues(t, expected.Dateutc, actual.Dateutc)
 }
 
 func toFloat64(data json.Number) float64 {
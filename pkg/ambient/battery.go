@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+package ambient
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// BatteryState tells whether a battery needs replacing.
+type BatteryState int
+
+const (
+	// BatteryUnknown is reported for values that cannot be read.
+	BatteryUnknown BatteryState = iota
+	// BatteryOK is reported for a good battery.
+	BatteryOK
+	// BatteryLow is reported for a battery that needs replacing.
+	BatteryLow
+)
+
+func (s BatteryState) String() string {
+	switch s {
+	case BatteryOK:
+		return "ok"
+	case BatteryLow:
+		return "low"
+	}
+	return "unknown"
+}
+
+// LowBatteryVoltage is the voltage under which
+// a battery reporting its voltage is low.
+const LowBatteryVoltage = 1.2
+
+// BatteryStatus is the battery of a sensor, as read from
+// a battery field of a Record.
+type BatteryStatus struct {
+	// Field is the API name of the battery field, e.g. "batt3".
+	Field string
+	// Label is the human readable name of the battery field.
+	Label string
+	// Raw is the value of the field.
+	Raw   json.Number
+	State BatteryState
+	// Voltage is set for sensors reporting the battery voltage.
+	Voltage    float64
+	HasVoltage bool
+	// Level is set for sensors reporting a battery level from 0 to 5,
+	// 6 when powered externally.
+	Level    int
+	HasLevel bool
+}
+
+// batteryOKFields are the battery fields reporting 1 for OK and
+// 0 for low.  The others, those of the WH31 family (channel sensors,
+// lightning and leak detectors), report 0 for OK and 1 for low.
+var batteryOKFields = map[string]bool{
+	"battin":           true,
+	"battout":          true,
+	"batt_co2":         true,
+	"batt_25":          true,
+	"batt_25in":        true,
+	"batt_cellgateway": true,
+}
+
+// newBatteryStatus reads value, the battery field field.  Values other
+// than 0 and 1 are read as levels when whole and as voltages otherwise.
+func newBatteryStatus(field Field, value json.Number) BatteryStatus {
+	status := BatteryStatus{Field: field.Key, Label: field.Label, Raw: value}
+	number, err := strconv.ParseFloat(value.String(), 64)
+	switch {
+	case err != nil:
+	case number == 0 || number == 1:
+		low := number == 0
+		if !batteryOKFields[field.Key] {
+			low = !low
+		}
+		status.State = BatteryOK
+		if low {
+			status.State = BatteryLow
+		}
+	case number == math.Trunc(number) && number >= 2 && number <= 6:
+		status.Level, status.HasLevel = int(number), true
+		status.State = BatteryOK
+	case number > 0 && number != math.Trunc(number):
+		status.Voltage, status.HasVoltage = number, true
+		status.State = BatteryOK
+		if number < LowBatteryVoltage {
+			status.State = BatteryLow
+		}
+	}
+	return status
+}
+
+// Batteries returns the status of every battery field held by record,
+// in Record order, reading each according to its sensor family.
+func (record Record) Batteries() []BatteryStatus {
+	var batteries []BatteryStatus
+	for _, field := range registry {
+		value, ok := field.value(record).(json.Number)
+		if !ok || !record.has(field) {
+			continue
+		}
+		batteries = append(batteries, newBatteryStatus(field, value))
+	}
+	return batteries
+}
+
+// LowBattery is a low battery of a device.
+type LowBattery struct {
+	Macaddress string
+	Name       string
+	Battery    BatteryStatus
+}
+
+// LowBatteries lists the low batteries of devices, as
+// reported in the LastData of each.
+func LowBatteries(devices []DeviceRecord) []LowBattery {
+	var low []LowBattery
+	for _, device := range devices {
+		for _, battery := range device.LastData.Batteries() {
+			if battery.State == BatteryLow {
+				low = append(low, LowBattery{Macaddress: device.Macaddress, Name: device.Info.Name, Battery: battery})
+			}
+		}
+	}
+	return low
+}
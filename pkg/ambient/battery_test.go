@@ -0,0 +1,66 @@
+package ambient
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Record_Batteries_ReadsEachFamily(t *testing.T) {
+	var record Record
+	require.Nil(t, json.Unmarshal([]byte(`{"battout":1,"battin":0,"batt1":0,"batt2":1,"batt_lightning":1,`+
+		`"batt_leak1":0,"batt_co2":"1","batt_25":4,"batt_cellgateway":1.05}`), &record))
+
+	states := make(map[string]BatteryState)
+	for _, battery := range record.Batteries() {
+		states[battery.Field] = battery.State
+	}
+
+	require.Equal(t, map[string]BatteryState{
+		"battout":          BatteryOK,
+		"battin":           BatteryLow,
+		"batt1":            BatteryOK,
+		"batt2":            BatteryLow,
+		"batt_lightning":   BatteryLow,
+		"batt_leak1":       BatteryOK,
+		"batt_co2":         BatteryOK,
+		"batt_25":          BatteryOK,
+		"batt_cellgateway": BatteryLow,
+	}, states)
+}
+
+func Test_Record_Batteries_LevelAndVoltage(t *testing.T) {
+	record := Record{Batt_25: "4", Batt_25in: "1.45"}
+
+	batteries := record.Batteries()
+
+	require.Equal(t, []BatteryStatus{
+		{Field: "batt_25", Label: "PM2.5 Battery", Raw: "4", State: BatteryOK, Level: 4, HasLevel: true},
+		{Field: "batt_25in", Label: "Indoor PM2.5 Battery", Raw: "1.45", State: BatteryOK, Voltage: 1.45, HasVoltage: true},
+	}, batteries)
+}
+
+func Test_Record_Batteries_Unreadable_IsUnknown(t *testing.T) {
+	batteries := Record{Batt_co2: "-1"}.Batteries()
+
+	require.Len(t, batteries, 1)
+	require.Equal(t, BatteryUnknown, batteries[0].State)
+	require.Equal(t, "unknown", batteries[0].State.String())
+}
+
+func Test_LowBatteries(t *testing.T) {
+	devices := []DeviceRecord{
+		{Macaddress: "AA", Info: DeviceInfo{Name: "Server Room"}, LastData: Record{Batt_leak2: "1", Battout: "1"}},
+		{Macaddress: "BB", Info: DeviceInfo{Name: "Greenhouse"}, LastData: Record{Batt3: "0", Battin: "0"}},
+	}
+
+	low := LowBatteries(devices)
+
+	require.Len(t, low, 2)
+	require.Equal(t, "AA", low[0].Macaddress)
+	require.Equal(t, "Server Room", low[0].Name)
+	require.Equal(t, "batt_leak2", low[0].Battery.Field)
+	require.Equal(t, "BB", low[1].Macaddress)
+	require.Equal(t, "battin", low[1].Battery.Field)
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+package ambient
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// MaxChannel is the highest sensor channel in a Record.
+const MaxChannel = 10
+
+// ChannelReading holds the fields of one sensor channel of a Record,
+// such as Temp3f, Humidity3 and Batt3 for channel 3.  Each value
+// comes with a flag telling whether the record holds it, as
+// reported by Record.Has.
+type ChannelReading struct {
+	Channel int
+
+	Temp            float64
+	HasTemp         bool
+	Humidity        int
+	HasHumidity     bool
+	Dewpoint        float64
+	HasDewpoint     bool
+	Feelslike       float64
+	HasFeelslike    bool
+	SoilTemp        float64
+	HasSoilTemp     bool
+	SoilHumidity    float64
+	HasSoilHumidity bool
+	Battery         json.Number
+	HasBattery      bool
+	Relay           int
+	HasRelay        bool
+}
+
+// Populated reports whether the channel holds any value.
+func (c ChannelReading) Populated() bool {
+	return c.HasTemp || c.HasHumidity || c.HasDewpoint || c.HasFeelslike ||
+		c.HasSoilTemp || c.HasSoilHumidity || c.HasBattery || c.HasRelay
+}
+
+// Channel returns the fields of sensor channel n, from 1 to MaxChannel,
+// and whether the channel holds any value.
+func (record Record) Channel(n int) (ChannelReading, bool) {
+	if n < 1 || n > MaxChannel {
+		return ChannelReading{}, false
+	}
+	c := ChannelReading{Channel: n}
+	ch := strconv.Itoa(n)
+	c.Temp, c.HasTemp = record.channelFloat("temp" + ch + "f")
+	c.Humidity, c.HasHumidity = record.channelInt("humidity" + ch)
+	c.Dewpoint, c.HasDewpoint = record.channelFloat("dewpoint" + ch)
+	c.Feelslike, c.HasFeelslike = record.channelFloat("feelslike" + ch)
+	c.SoilTemp, c.HasSoilTemp = record.channelFloat("soiltemp" + ch + "f")
+	c.SoilHumidity, c.HasSoilHumidity = record.channelFloat("soilhum" + ch)
+	c.Relay, c.HasRelay = record.channelInt("relay" + ch)
+	field, _ := LookupField("batt" + ch)
+	c.Battery = field.value(record).(json.Number)
+	c.HasBattery = record.has(field)
+	return c, c.Populated()
+}
+
+// Channels returns the populated sensor channels, in channel order.
+func (record Record) Channels() []ChannelReading {
+	var channels []ChannelReading
+	for n := 1; n <= MaxChannel; n++ {
+		if c, ok := record.Channel(n); ok {
+			channels = append(channels, c)
+		}
+	}
+	return channels
+}
+
+func (record Record) channelFloat(key string) (float64, bool) {
+	field, _ := LookupField(key)
+	return field.value(record).(float64), record.has(field)
+}
+
+func (record Record) channelInt(key string) (int, bool) {
+	field, _ := LookupField(key)
+	return field.value(record).(int), record.has(field)
+}
@@ -0,0 +1,76 @@
+package ambient
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Record_Channel(t *testing.T) {
+	record := Record{Temp3f: 68.2, Humidity3: 47, Dewpoint3: 47.1, Feelslike3: 68.2, Batt3: "1", Soilhum3: 31}
+
+	channel, ok := record.Channel(3)
+
+	require.True(t, ok)
+	require.Equal(t, ChannelReading{
+		Channel:         3,
+		Temp:            68.2,
+		HasTemp:         true,
+		Humidity:        47,
+		HasHumidity:     true,
+		Dewpoint:        47.1,
+		HasDewpoint:     true,
+		Feelslike:       68.2,
+		HasFeelslike:    true,
+		SoilHumidity:    31,
+		HasSoilHumidity: true,
+		Battery:         json.Number("1"),
+		HasBattery:      true,
+	}, channel)
+}
+
+func Test_Record_Channel_Empty(t *testing.T) {
+	channel, ok := Record{Temp1f: 50}.Channel(2)
+
+	require.False(t, ok)
+	require.Equal(t, ChannelReading{Channel: 2}, channel)
+}
+
+func Test_Record_Channel_OutOfRange(t *testing.T) {
+	for _, n := range []int{0, MaxChannel + 1} {
+		_, ok := Record{}.Channel(n)
+		require.False(t, ok, n)
+	}
+}
+
+func Test_Record_Channels_ReturnsPopulatedInOrder(t *testing.T) {
+	record := Record{Temp1f: 50, Soiltemp4f: 45.3, Relay10: 1, Tempf: 60}
+
+	channels := record.Channels()
+
+	require.Len(t, channels, 3)
+	require.Equal(t, 1, channels[0].Channel)
+	require.Equal(t, 50.0, channels[0].Temp)
+	require.Equal(t, 4, channels[1].Channel)
+	require.Equal(t, 45.3, channels[1].SoilTemp)
+	require.True(t, channels[1].HasSoilTemp)
+	require.False(t, channels[1].HasTemp)
+	require.Equal(t, 10, channels[2].Channel)
+	require.Equal(t, 1, channels[2].Relay)
+}
+
+func Test_Record_Channel_FakeRecord(t *testing.T) {
+	record := getValidDeviceRecord().LastData
+
+	channel, _ := record.Channel(5)
+
+	require.Equal(t, record.Temp5f, channel.Temp)
+	require.Equal(t, record.Humidity5, channel.Humidity)
+	require.Equal(t, record.Dewpoint5, channel.Dewpoint)
+	require.Equal(t, record.Feelslike5, channel.Feelslike)
+	require.Equal(t, record.Soiltemp5f, channel.SoilTemp)
+	require.Equal(t, record.Soilhum5, channel.SoilHumidity)
+	require.Equal(t, record.Batt5, channel.Battery)
+	require.Equal(t, record.Relay5, channel.Relay)
+}
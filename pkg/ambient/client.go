@@ -0,0 +1,359 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+package ambient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultUserAgent is sent with every request unless
+// overridden with WithUserAgent.
+const DefaultUserAgent = "github.com/lrosenman/ambient"
+
+// Client issues calls against the API.
+// A Client is safe for concurrent use and
+// may be shared between any number of Keys.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	userAgent  string
+	timeout    time.Duration
+	limiter    *RateLimiter
+	retry      *RetryPolicy
+	keepJSON   bool
+	strict     bool
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the *http.Client used to issue requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithBaseURL sets the endpoint to be called, e.g. a local
+// fake server in tests.  It defaults to APIEP.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithTimeout bounds the time taken by each request, including
+// reading the response body.  Zero means no timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRateLimiter sets the RateLimiter consulted before every request.
+// Clients sharing keys should share a RateLimiter.  A nil limiter
+// disables rate limiting.  By default each Client has its own
+// blocking limiter set to APIKeyRate and ApplicationKeyRate.
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry failed requests.
+// By default, and with a nil policy, requests are not retried.
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// WithJSONResponse sets whether responses keep the body returned by
+// the API in JSONResponse, which they do by default.  Clients only
+// using the decoded records can save memory by turning it off.
+func WithJSONResponse(keep bool) Option {
+	return func(c *Client) {
+		c.keepJSON = keep
+	}
+}
+
+// WithStrictDecoding sets whether an *UnknownFieldsError is returned
+// when records hold fields that Record does not model, to notice
+// changes of the API early.  It is off by default.
+func WithStrictDecoding(strict bool) Option {
+	return func(c *Client) {
+		c.strict = strict
+	}
+}
+
+// NewClient returns a Client configured with opts.
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		httpClient: http.DefaultClient,
+		baseURL:    APIEP,
+		userAgent:  DefaultUserAgent,
+		limiter:    NewRateLimiter(APIKeyRate, ApplicationKeyRate, LimitBlock),
+		keepJSON:   true,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.httpClient == nil {
+		c.httpClient = http.DefaultClient
+	}
+	return c
+}
+
+// DefaultClient is the Client used by the package-level
+// Device, DeviceContext, DeviceMac and DeviceMacContext functions.
+var DefaultClient = NewClient()
+
+// endpoint returns the URL for path with query and the
+// credentials of key properly encoded.
+func (c *Client) endpoint(key Key, path string, query url.Values) string {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("applicationKey", key.applicationKey)
+	query.Set("apiKey", key.apiKey)
+	return c.baseURL + path + "?" + query.Encode()
+}
+
+// wait blocks until the rate limiter allows a request for key.
+func (c *Client) wait(ctx context.Context, key Key) error {
+	if c.limiter == nil {
+		return nil
+	}
+	return c.limiter.Wait(ctx, key)
+}
+
+// observe feeds the outcome of a request for key back to the rate limiter.
+func (c *Client) observe(key Key, resp *http.Response) {
+	if c.limiter == nil || resp == nil {
+		return
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		c.limiter.Succeeded(key)
+	case http.StatusTooManyRequests:
+		c.limiter.Throttled(key, parseRetryAfter(resp, time.Now()))
+	}
+}
+
+// get issues a GET for apiurl on behalf of key, waiting for the rate
+// limiter and retrying as the RetryPolicy allows, and returns the last
+// response, its body as getOnce does and the time that attempt took.
+func (c *Client) get(ctx context.Context, key Key, apiurl string, decode func(io.Reader) error) (*http.Response, []byte, time.Duration, error) {
+	var (
+		resp    *http.Response
+		body    []byte
+		elapsed time.Duration
+		err     error
+	)
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		if werr := c.wait(ctx, key); werr != nil {
+			if attempt > 1 && errors.Is(werr, ErrLimiterWouldBlock) {
+				return resp, body, elapsed, err
+			}
+			return nil, nil, 0, werr
+		}
+		attemptStart := time.Now()
+		resp, body, err = c.getOnce(ctx, apiurl, decode)
+		elapsed = time.Since(attemptStart)
+		c.observe(key, resp)
+		if !c.retry.shouldRetry(http.MethodGet, attempt, resp, err) {
+			return resp, body, elapsed, err
+		}
+		delay := c.retry.delay(attempt, resp)
+		if c.retry.MaxElapsed > 0 && time.Since(start)+delay > c.retry.MaxElapsed {
+			return resp, body, elapsed, err
+		}
+		if serr := sleepContext(ctx, delay); serr != nil {
+			return nil, nil, 0, serr
+		}
+	}
+}
+
+// getOnce issues a single GET for apiurl.  The body of a successful
+// response is decoded by decode as it is read, and only returned if
+// the Client keeps JSON responses or it is not a JSON array, in which
+// case errNotArray is returned.  Other bodies are read whole.
+// Cancellation of ctx aborts both the request and the body read.
+func (c *Client) getOnce(ctx context.Context, apiurl string, decode func(io.Reader) error) (*http.Response, []byte, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiurl, nil)
+	if err != nil {
+		return nil, nil, redactError(err)
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, redactError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		return resp, body, redactError(err)
+	}
+	body, err := c.decodeBody(resp.Body, decode)
+	return resp, body, redactError(err)
+}
+
+// decodeBody calls decode with a reader of body, keeping a copy of it
+// if the Client keeps JSON responses.  A body that is not a JSON array
+// or null is read whole instead and returned with errNotArray.
+func (c *Client) decodeBody(body io.Reader, decode func(io.Reader) error) ([]byte, error) {
+	var kept bytes.Buffer
+	if c.keepJSON {
+		body = io.TeeReader(body, &kept)
+	}
+	r := bufio.NewReader(body)
+	first, err := firstNonSpace(r)
+	if err == nil && first != '[' && first != 'n' {
+		rest, err := io.ReadAll(r)
+		if c.keepJSON {
+			rest = kept.Bytes()
+		}
+		if err != nil {
+			return rest, err
+		}
+		return rest, errNotArray
+	}
+	err = decode(r)
+	// Read what follows the array, so that JSONResponse
+	// is whole and the connection can be reused.
+	if _, cerr := io.Copy(io.Discard, r); err == nil {
+		err = cerr
+	}
+	if c.keepJSON {
+		return kept.Bytes(), err
+	}
+	return nil, err
+}
+
+// firstNonSpace returns the first byte of r that
+// is not JSON white space, leaving it unread.
+func firstNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\n', '\r':
+			_, _ = r.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Device issues a /devices call.
+func (c *Client) Device(key Key) (APIDeviceResponse, error) {
+	return c.DeviceContext(context.Background(), key)
+}
+
+// DeviceContext issues a /devices call bound to ctx.
+func (c *Client) DeviceContext(ctx context.Context, key Key) (APIDeviceResponse, error) {
+	var ar APIDeviceResponse
+
+	apiurl := c.endpoint(key, "/devices", nil)
+	resp, body, elapsed, err := c.get(ctx, key, apiurl, func(r io.Reader) error {
+		var err error
+		ar.DeviceRecord, err = decodeDevices(r)
+		return err
+	})
+	ar.ResponseTime = elapsed
+	if resp == nil {
+		return ar, err
+	}
+	ar.HTTPResponseCode = resp.StatusCode
+	if c.keepJSON {
+		ar.JSONResponse = body
+	}
+	if errors.Is(err, errNotArray) || (err == nil && resp.StatusCode != http.StatusOK) {
+		return ar, &APIError{StatusCode: resp.StatusCode, Endpoint: redactURL(apiurl), Body: body}
+	}
+	if err == nil && c.strict {
+		records := make([]Record, 0, len(ar.DeviceRecord))
+		for _, device := range ar.DeviceRecord {
+			records = append(records, device.LastData)
+		}
+		err = unknownFields(records...)
+	}
+	return ar, err
+}
+
+// DeviceMac issues a /devices/macaddr call.
+func (c *Client) DeviceMac(key Key, macaddr string, endtime time.Time, limit int64) (APIDeviceMacResponse, error) {
+	return c.DeviceMacContext(context.Background(), key, macaddr, endtime, limit)
+}
+
+// DeviceMacContext issues a /devices/macaddr call bound to ctx.
+func (c *Client) DeviceMacContext(
+	ctx context.Context, key Key, macaddr string, endtime time.Time, limit int64,
+) (APIDeviceMacResponse, error) {
+	var ar APIDeviceMacResponse
+	apiurl := c.endpoint(key, "/devices/"+url.PathEscape(macaddr), url.Values{
+		"endDate": {endtime.Format(time.RFC3339)},
+		"limit":   {strconv.FormatInt(limit, 10)},
+	})
+	resp, body, elapsed, err := c.get(ctx, key, apiurl, func(r io.Reader) error {
+		var err error
+		ar.Record, ar.RecordFields, err = decodeRecords(r)
+		return err
+	})
+	ar.ResponseTime = elapsed
+	if resp == nil {
+		return ar, err
+	}
+	ar.HTTPResponseCode = resp.StatusCode
+	if c.keepJSON {
+		ar.JSONResponse = body
+	}
+	if errors.Is(err, errNotArray) || (err == nil && resp.StatusCode != http.StatusOK) {
+		return ar, &APIError{StatusCode: resp.StatusCode, Endpoint: redactURL(apiurl), Body: body}
+	}
+	if err == nil && c.strict {
+		err = unknownFields(ar.Record...)
+	}
+	return ar, err
+}
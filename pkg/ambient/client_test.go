@@ -0,0 +1,119 @@
+package ambient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewClient_Defaults(t *testing.T) {
+	client := NewClient()
+
+	require.Equal(t, http.DefaultClient, client.httpClient)
+	require.Equal(t, APIEP, client.baseURL)
+	require.Equal(t, DefaultUserAgent, client.userAgent)
+	require.Zero(t, client.timeout)
+}
+
+func Test_Client_Device_UsesBaseURLAndUserAgent(t *testing.T) {
+	var gotPath, gotUserAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotUserAgent = r.Header.Get("User-Agent")
+		_, _ = w.Write([]byte(mapToJson([]*DeviceRecord{getValidDeviceRecord()})))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL+"/v1/"), WithUserAgent("station-poller/1.0"))
+	devices, err := client.Device(NewKey("application-key", "api-key"))
+
+	require.Nil(t, err)
+	require.Equal(t, "/v1/devices", gotPath)
+	require.Equal(t, "station-poller/1.0", gotUserAgent)
+	require.Len(t, devices.DeviceRecord, 1)
+}
+
+func Test_Client_DeviceMac_UsesBaseURL(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_, _ = w.Write([]byte(mapToJson(getValidRecordSlice(3))))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL))
+	queryResults, err := client.DeviceMac(NewKey("application-key", "api-key"), "00:0E:C6:10:01:86", time.Now(), 3)
+
+	require.Nil(t, err)
+	require.Equal(t, "/devices/00:0E:C6:10:01:86", gotPath)
+	require.Len(t, queryResults.Record, 3)
+}
+
+func Test_Client_Timeout_ReturnsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithTimeout(10*time.Millisecond))
+	_, err := client.Device(NewKey("application-key", "api-key"))
+
+	require.NotNil(t, err)
+}
+
+func Test_Device_UsesDefaultClient(t *testing.T) {
+	saved := DefaultClient
+	defer func() { DefaultClient = saved }()
+	expectedResult := []*DeviceRecord{getValidDeviceRecord()}
+	DefaultClient = getMockClient(http.StatusOK, expectedResult)
+
+	devices, err := Device(NewKey("application-key", "api-key"))
+
+	require.Nil(t, err)
+	requireDeviceRecordsEqualValues(t, expectedResult, devices.DeviceRecord)
+}
+
+func Test_Client_DeviceContext_CancelledContext_ReturnsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	client := NewClient(WithBaseURL(server.URL))
+	_, err := client.DeviceContext(ctx, NewKey("application-key", "api-key"))
+
+	require.True(t, errors.Is(err, context.Canceled))
+}
+
+func Test_Client_DeviceMacContext_DeadlineDuringBody_ReturnsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("["))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	client := NewClient(WithBaseURL(server.URL))
+	queryResults, err := client.DeviceMacContext(ctx, NewKey("application-key", "api-key"), "mac", time.Now(), 1)
+
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Equal(t, http.StatusOK, queryResults.HTTPResponseCode)
+	require.Empty(t, queryResults.Record)
+}
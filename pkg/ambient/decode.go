@@ -0,0 +1,436 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+package ambient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errNotArray is returned when a response is not the expected
+// JSON array, e.g. an object describing an error.
+var errNotArray = errors.New("ambient: response is not an array")
+
+var (
+	recordType = reflect.TypeOf(Record{})
+	numberType = reflect.TypeOf(json.Number(""))
+	timeType   = reflect.TypeOf(time.Time{})
+)
+
+// decodeArray calls element for each element of the JSON array read
+// by dec.  It returns errNotArray if dec holds anything but an array
+// or null.  Type errors returned by element are collected and the
+// first one is returned once the whole array is decoded, like
+// json.Unmarshal does.
+func decodeArray(dec *json.Decoder, element func() error) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if token != json.Delim('[') {
+		return errNotArray
+	}
+	var typeErr error
+	for dec.More() {
+		if err := keepTypeError(&typeErr, element()); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	return typeErr
+}
+
+// keepTypeError stores err in *first if it is the first type error
+// met, and returns err if it is any other error.
+func keepTypeError(first *error, err error) error {
+	if !isTypeError(err) {
+		return err
+	}
+	if *first == nil {
+		*first = err
+	}
+	return nil
+}
+
+// isTypeError reports whether err is a *json.UnmarshalTypeError.
+func isTypeError(err error) bool {
+	var typeErr *json.UnmarshalTypeError
+	return errors.As(err, &typeErr)
+}
+
+// decodeDevices decodes the response of the /devices API read from r.
+func decodeDevices(r io.Reader) ([]DeviceRecord, error) {
+	dec := json.NewDecoder(r)
+	var records recordDecoder
+	var devices []DeviceRecord
+	err := decodeArray(dec, func() error {
+		var device DeviceRecord
+		err := decodeDevice(dec, &records, &device)
+		if err == nil || isTypeError(err) {
+			devices = append(devices, device)
+		}
+		return err
+	})
+	return devices, err
+}
+
+// decodeDevice decodes one element of the /devices API.
+func decodeDevice(dec *json.Decoder, records *recordDecoder, device *DeviceRecord) error {
+	return decodeObject(dec, reflect.TypeOf(DeviceRecord{}), func(key string) error {
+		switch strings.ToLower(key) {
+		case "macaddress":
+			return dec.Decode(&device.Macaddress)
+		case "info":
+			return dec.Decode(&device.Info)
+		case "lastdata":
+			var err error
+			device.LastDataFields, err = records.decode(dec, &device.LastData)
+			return err
+		case "lastdatafields":
+			// Only found in a DeviceRecord encoded with json.Marshal.
+			var fields map[string]interface{}
+			err := dec.Decode(&fields)
+			device.LastDataFields = fields
+			return err
+		}
+		var skip json.RawMessage
+		return dec.Decode(&skip)
+	})
+}
+
+// decodeRecords decodes the response of the /devices/macaddr API
+// read from r.
+func decodeRecords(r io.Reader) ([]Record, []map[string]interface{}, error) {
+	dec := json.NewDecoder(r)
+	var decoder recordDecoder
+	var records []Record
+	var fields []map[string]interface{}
+	err := decodeArray(dec, func() error {
+		var record Record
+		recordFields, err := decoder.decode(dec, &record)
+		if err == nil || isTypeError(err) {
+			records = append(records, record)
+			fields = append(fields, recordFields)
+		}
+		return err
+	})
+	return records, fields, err
+}
+
+// decodeObject calls member for each member of the JSON object read
+// by dec, which is left to decode its value.  A null is skipped.
+// Type errors are handled like decodeArray does.
+func decodeObject(dec *json.Decoder, objectType reflect.Type, member func(key string) error) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if token != json.Delim('{') {
+		if delim, ok := token.(json.Delim); ok && delim == '[' {
+			// Skip the rest of the array to keep the decoder in step.
+			for dec.More() {
+				var skip json.RawMessage
+				if err := dec.Decode(&skip); err != nil {
+					return err
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+		}
+		return &json.UnmarshalTypeError{Value: jsonKind(token), Type: objectType, Offset: dec.InputOffset()}
+	}
+	var typeErr error
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if err := keepTypeError(&typeErr, member(token.(string))); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	return typeErr
+}
+
+// recordDecoder decodes the records of a response.  The keys met
+// are remembered with their Field, so that the keys repeated by every
+// record are only unquoted and looked up once.
+type recordDecoder struct {
+	keys map[string]recordKey
+	// raw holds the record being decoded.  It is reused for the
+	// next record unless Extra refers to it.
+	raw json.RawMessage
+}
+
+// recordKey is a key met in a record.
+type recordKey struct {
+	name string
+	// field is the index of the Field of name in the
+	// registry, or -1 if Record does not model it.
+	field int
+}
+
+// key returns the key quoted, as found in a record.
+func (d *recordDecoder) key(quoted []byte) (recordKey, error) {
+	if key, ok := d.keys[string(quoted)]; ok {
+		return key, nil
+	}
+	key := recordKey{field: -1}
+	if bytes.IndexByte(quoted, '\\') < 0 {
+		key.name = string(quoted[1 : len(quoted)-1])
+	} else if err := json.Unmarshal(quoted, &key.name); err != nil {
+		return key, err
+	}
+	if i, ok := registryIndex[strings.ToLower(key.name)]; ok {
+		key.field = i
+	}
+	if d.keys == nil {
+		d.keys = make(map[string]recordKey)
+	}
+	d.keys[string(quoted)] = key
+	return key, nil
+}
+
+// decode decodes the record read by dec into record, returning its
+// fields as json.Unmarshal would decode them into an interface{}.
+// Known fields are decoded straight into record, noting which ones it
+// holds, and the others are kept in Extra as found.  A field of the
+// wrong type is left unchanged and the first such error is returned
+// once the whole record is decoded.
+func (d *recordDecoder) decode(dec *json.Decoder, record *Record) (map[string]interface{}, error) {
+	if err := dec.Decode(&d.raw); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{}, len(d.keys))
+	data := d.raw
+	i := skipSpace(data, 0)
+	if data[i] == 'n' {
+		record.present = presence{decoded: true}
+		return fields, nil
+	}
+	if data[i] != '{' {
+		value, _ := plainValue(data[i:valueEnd(data, i)])
+		return fields, &json.UnmarshalTypeError{Value: jsonKind(value), Type: recordType, Offset: dec.InputOffset()}
+	}
+
+	target := reflect.ValueOf(record).Elem()
+	present := presence{decoded: true}
+	var extra map[string]json.RawMessage
+	var typeErr error
+	for i = skipSpace(data, i+1); data[i] != '}'; {
+		end := stringEnd(data, i)
+		key, err := d.key(data[i:end])
+		if err != nil {
+			return fields, err
+		}
+		// Skip the colon.
+		i = skipSpace(data, skipSpace(data, end)+1)
+		end = valueEnd(data, i)
+		raw := data[i:end:end]
+		if i = skipSpace(data, end); data[i] == ',' {
+			i = skipSpace(data, i+1)
+		}
+
+		value, err := plainValue(raw)
+		if err != nil {
+			return fields, err
+		}
+		fields[key.name] = value
+		if key.field < 0 {
+			if extra == nil {
+				extra = make(map[string]json.RawMessage)
+			}
+			extra[key.name] = raw
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		field := &registry[key.field]
+		if err := field.set(target.Field(field.index), raw, value); err != nil {
+			if typeErr == nil {
+				typeErr = err
+			}
+			continue
+		}
+		present.set(field.position)
+	}
+	record.present = present
+	record.Extra = extra
+	if extra != nil {
+		// Extra refers to raw: the next record needs its own.
+		d.raw = nil
+	}
+	return fields, typeErr
+}
+
+// set stores the JSON value raw, decoded into value by plainValue,
+// in target, the field f of a Record.
+func (f *Field) set(target reflect.Value, raw []byte, value interface{}) error {
+	switch target.Type() {
+	case numberType:
+		switch v := value.(type) {
+		case float64:
+			target.SetString(string(raw))
+			return nil
+		case string:
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				target.SetString(v)
+				return nil
+			}
+		}
+	case timeType:
+		if s, ok := value.(string); ok {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return f.typeError(target, "string "+strconv.Quote(s))
+			}
+			*target.Addr().Interface().(*time.Time) = t
+			return nil
+		}
+	default:
+		switch target.Kind() {
+		case reflect.Float64:
+			if n, ok := value.(float64); ok {
+				target.SetFloat(n)
+				return nil
+			}
+		case reflect.Int, reflect.Int64:
+			if _, ok := value.(float64); ok {
+				n, err := strconv.ParseInt(string(raw), 10, 64)
+				if err != nil {
+					return f.typeError(target, "number "+string(raw))
+				}
+				target.SetInt(n)
+				return nil
+			}
+		case reflect.String:
+			if s, ok := value.(string); ok {
+				target.SetString(s)
+				return nil
+			}
+		}
+	}
+	return f.typeError(target, jsonKind(value))
+}
+
+// typeError returns the error for a value of kind kind
+// found for f, stored in target.
+func (f *Field) typeError(target reflect.Value, kind string) error {
+	return &json.UnmarshalTypeError{Value: kind, Type: target.Type(), Struct: "Record", Field: f.Name}
+}
+
+// plainValue decodes the valid JSON value data like json.Unmarshal
+// into an interface{}, without its reflection for strings, numbers
+// and literals.
+func plainValue(data []byte) (interface{}, error) {
+	switch data[0] {
+	case '"':
+		if bytes.IndexByte(data, '\\') < 0 {
+			return string(data[1 : len(data)-1]), nil
+		}
+	case 'n':
+		return nil, nil
+	case 't':
+		return true, nil
+	case 'f':
+		return false, nil
+	case '{', '[':
+	default:
+		if n, err := strconv.ParseFloat(string(data), 64); err == nil {
+			return n, nil
+		}
+	}
+	var value interface{}
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// skipSpace returns the index of the first byte of data
+// from i on that is not JSON white space.
+func skipSpace(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\n' || data[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// stringEnd returns the index following the end of the
+// valid JSON string starting at data[i].
+func stringEnd(data []byte, i int) int {
+	for i++; data[i] != '"'; i++ {
+		if data[i] == '\\' {
+			i++
+		}
+	}
+	return i + 1
+}
+
+// valueEnd returns the index following the end of the
+// valid JSON value starting at data[i].
+func valueEnd(data []byte, i int) int {
+	switch data[i] {
+	case '"':
+		return stringEnd(data, i)
+	case '{', '[':
+		for depth := 0; ; {
+			switch data[i] {
+			case '"':
+				i = stringEnd(data, i)
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth--; depth == 0 {
+					return i + 1
+				}
+			}
+			i++
+		}
+	}
+	for i < len(data) && !strings.ContainsRune(",}] \t\n\r", rune(data[i])) {
+		i++
+	}
+	return i
+}
+
+// jsonKind names the kind of a JSON value like json.UnmarshalTypeError.
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case float64, json.Number:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case []interface{}, json.Delim:
+		if value == json.Delim('{') {
+			return "object"
+		}
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "null"
+}
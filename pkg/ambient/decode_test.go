@@ -0,0 +1,302 @@
+package ambient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Device_ObjectBody_ReturnsAPIError(t *testing.T) {
+	client := getMockClient(http.StatusOK, map[string]string{"error": "apiKey-missing"})
+
+	results, err := client.Device(NewKey("app", "api"))
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusOK, apiErr.StatusCode)
+	require.Contains(t, string(apiErr.Body), "apiKey-missing")
+	require.Empty(t, results.DeviceRecord)
+}
+
+func Test_DeviceMac_ObjectBody_ReturnsAPIError(t *testing.T) {
+	client := getMockClient(http.StatusOK, map[string]string{"error": "date-invalid"})
+
+	_, err := client.DeviceMac(NewKey("app", "api"), "00:0E:C6:10:01:86", time.Now(), 1)
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Contains(t, apiErr.Error(), "date-invalid")
+}
+
+func Test_DeviceMac_WithJSONResponseFalse_DropsBody(t *testing.T) {
+	client := getMockClient(http.StatusOK, json.RawMessage(`[{"tempf":50}]`))
+	WithJSONResponse(false)(client)
+
+	results, err := client.DeviceMac(NewKey("app", "api"), "00:0E:C6:10:01:86", time.Now(), 1)
+
+	require.Nil(t, err)
+	require.Nil(t, results.JSONResponse)
+	require.Equal(t, 50.0, results.Record[0].Tempf)
+}
+
+func Test_DeviceMac_WithJSONResponseFalse_ObjectBody_ReturnsAPIError(t *testing.T) {
+	client := getMockClient(http.StatusOK, map[string]string{"error": "date-invalid"})
+	WithJSONResponse(false)(client)
+
+	results, err := client.DeviceMac(NewKey("app", "api"), "00:0E:C6:10:01:86", time.Now(), 1)
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, `{"error":"date-invalid"}`, string(apiErr.Body))
+	require.Nil(t, results.JSONResponse)
+}
+
+func Test_DeviceMac_JSONResponse_KeepsWholeBody(t *testing.T) {
+	body := " [{\"tempf\":50},\n{\"tempf\":49}]\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithRateLimiter(nil))
+	results, err := client.DeviceMac(NewKey("app", "api"), "00:0E:C6:10:01:86", time.Now(), 2)
+
+	require.Nil(t, err)
+	require.Equal(t, body, string(results.JSONResponse))
+	require.Len(t, results.Record, 2)
+	require.Equal(t, 49.0, results.Record[1].Tempf)
+}
+
+func Test_decodeRecords_FillsRecordsAndFields(t *testing.T) {
+	records, fields, err := decodeRecords(strings.NewReader(`[
+		{"date":"2023-01-02T03:05:00.000Z","tempf":50.5,"humidity":40,"batt1":"1","battout":1.0,"tz":"America/Chicago","leak1":0,"nested":{"a":[1]}},
+		{"date":"2023-01-02T03:00:00.000Z","tempf":49}
+	]`))
+
+	require.Nil(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, time.Date(2023, 1, 2, 3, 5, 0, 0, time.UTC), records[0].Date)
+	require.Equal(t, 50.5, records[0].Tempf)
+	require.Equal(t, 40, records[0].Humidity)
+	require.Equal(t, json.Number("1"), records[0].Batt1)
+	require.Equal(t, json.Number("1.0"), records[0].Battout)
+	require.Equal(t, "America/Chicago", records[0].TZ)
+	require.Equal(t, 49.0, records[1].Tempf)
+	require.Len(t, fields, 2)
+	require.Equal(t, 50.5, fields[0]["tempf"])
+	require.Equal(t, 40.0, fields[0]["humidity"])
+	require.Equal(t, "1", fields[0]["batt1"])
+	require.Equal(t, 0.0, fields[0]["leak1"])
+	require.Equal(t, map[string]interface{}{"a": []interface{}{1.0}}, fields[0]["nested"])
+	require.Equal(t, map[string]interface{}{"date": "2023-01-02T03:00:00.000Z", "tempf": 49.0}, fields[1])
+}
+
+func Test_decodeRecords_EscapesAndWhiteSpace(t *testing.T) {
+	records, fields, err := decodeRecords(strings.NewReader(`[ { "tz" : "A\"}]" ,` +
+		"\n\t\"we\\u0069rd\":{\"a\":[\"}\",1]} , \"tempf\":-1.5e1,\"leak\\u0031\":1 } ]"))
+
+	require.Nil(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, `A"}]`, records[0].TZ)
+	require.Equal(t, -15.0, records[0].Tempf)
+	require.Equal(t, 1, records[0].Leak1)
+	require.Equal(t, json.RawMessage(`{"a":["}",1]}`), records[0].Extra["weird"])
+	require.Equal(t, map[string]interface{}{"a": []interface{}{"}", 1.0}}, fields[0]["weird"])
+	require.Equal(t, `A"}]`, fields[0]["tz"])
+}
+
+func Test_Record_UnmarshalJSON_Malformed_ReturnsError(t *testing.T) {
+	var record Record
+
+	require.NotNil(t, record.UnmarshalJSON([]byte(`{"tempf":`)))
+	require.NotNil(t, record.UnmarshalJSON([]byte(``)))
+}
+
+func Test_decodeRecords_TypeError_DecodesTheRest(t *testing.T) {
+	records, fields, err := decodeRecords(strings.NewReader(`[{"humidity":41.5,"tempf":50},"oops",{"tempf":49,"date":"yesterday"}]`))
+
+	var typeErr *json.UnmarshalTypeError
+	require.True(t, errors.As(err, &typeErr))
+	require.Equal(t, "Humidity", typeErr.Field)
+	require.Len(t, records, 3)
+	require.Equal(t, 50.0, records[0].Tempf)
+	require.False(t, records[0].Has("humidity"))
+	require.Equal(t, 41.5, fields[0]["humidity"])
+	require.Equal(t, 49.0, records[2].Tempf)
+	require.True(t, records[2].Date.IsZero())
+}
+
+func Test_decodeRecords_Null_ReturnsNoRecords(t *testing.T) {
+	records, fields, err := decodeRecords(strings.NewReader(`null`))
+
+	require.Nil(t, err)
+	require.Empty(t, records)
+	require.Empty(t, fields)
+}
+
+func Test_decodeRecords_Malformed_ReturnsError(t *testing.T) {
+	_, _, err := decodeRecords(strings.NewReader(`[{"tempf":50`))
+
+	require.NotNil(t, err)
+}
+
+func Test_decodeDevices_SkipsUnknownKeys(t *testing.T) {
+	devices, err := decodeDevices(strings.NewReader(`[{"macAddress":"00:0E:C6:10:01:86","apiKey":"x","info":{"name":"Backyard"},"lastData":{"tempf":50}}]`))
+
+	require.Nil(t, err)
+	require.Len(t, devices, 1)
+	require.Equal(t, "00:0E:C6:10:01:86", devices[0].Macaddress)
+	require.Equal(t, "Backyard", devices[0].Info.Name)
+	require.Equal(t, 50.0, devices[0].LastData.Tempf)
+	require.Equal(t, map[string]interface{}{"tempf": 50.0}, devices[0].LastDataFields)
+}
+
+func Test_decodeRecords_KeepsUnknownFieldsInExtra(t *testing.T) {
+	records, _, err := decodeRecords(strings.NewReader(`[{"tempf":50,"leak5":0,"pm10_in":12.30,"tempf_co2":null},{"tempf":49}]`))
+
+	require.Nil(t, err)
+	require.Equal(t, map[string]json.RawMessage{
+		"leak5":     json.RawMessage(`0`),
+		"pm10_in":   json.RawMessage(`12.30`),
+		"tempf_co2": json.RawMessage(`null`),
+	}, records[0].Extra)
+	require.Nil(t, records[1].Extra)
+}
+
+func Test_DeviceMac_StrictDecoding_ReportsUnknownFields(t *testing.T) {
+	client := getMockClient(http.StatusOK, json.RawMessage(`[{"tempf":50,"soilhum11":1},{"tempf":49,"pm10_in":0,"soilhum11":0}]`))
+	WithStrictDecoding(true)(client)
+
+	results, err := client.DeviceMac(NewKey("app", "api"), "00:0E:C6:10:01:86", time.Now(), 2)
+
+	var unknown *UnknownFieldsError
+	require.True(t, errors.As(err, &unknown))
+	require.Equal(t, []string{"pm10_in", "soilhum11"}, unknown.Fields)
+	require.Equal(t, "ambient: unknown fields pm10_in, soilhum11", err.Error())
+	require.Len(t, results.Record, 2)
+	require.Equal(t, json.RawMessage(`1`), results.Record[0].Extra["soilhum11"])
+}
+
+func Test_Device_StrictDecoding_KnownFields_ReturnsNoError(t *testing.T) {
+	client := getMockClient(http.StatusOK, json.RawMessage(`[{"macAddress":"00:0E:C6:10:01:86","lastData":{"tempf":50}}]`))
+	WithStrictDecoding(true)(client)
+
+	_, err := client.Device(NewKey("app", "api"))
+
+	require.Nil(t, err)
+}
+
+func Test_Device_StrictDecoding_ReportsUnknownFields(t *testing.T) {
+	client := getMockClient(http.StatusOK, json.RawMessage(`[{"macAddress":"00:0E:C6:10:01:86","lastData":{"aqi_pm10":31}}]`))
+	WithStrictDecoding(true)(client)
+
+	results, err := client.Device(NewKey("app", "api"))
+
+	var unknown *UnknownFieldsError
+	require.True(t, errors.As(err, &unknown))
+	require.Equal(t, []string{"aqi_pm10"}, unknown.Fields)
+	require.Len(t, results.DeviceRecord, 1)
+}
+
+func Test_decodeRecords_NewSensorFamilies(t *testing.T) {
+	records, _, err := decodeRecords(strings.NewReader(`[{"dateutc":1672628700000,"leak1":0,"leak3":1,"batt_leak3":1,` +
+		`"pm25_in":8.5,"pm25_in_24h":9.25,"aqi_pm25":31,"aqi_pm25_24h":28,"co2_in":612,"co2_in_24h":590,` +
+		`"batt_25":1,"batt_25in":0,"batt_cellgateway":1}]`))
+
+	require.Nil(t, err)
+	record := records[0]
+	require.Equal(t, int64(1672628700000), record.Dateutc)
+	require.Equal(t, 1, record.Leak3)
+	require.True(t, record.Has("leak1"))
+	require.False(t, record.Has("leak2"))
+	require.Equal(t, json.Number("1"), record.Batt_leak3)
+	require.Equal(t, 8.5, record.Pm25_in)
+	require.Equal(t, 9.25, record.Pm25_in_24h)
+	require.Equal(t, 31, record.Aqi_pm25)
+	require.Equal(t, 28, record.Aqi_pm25_24h)
+	require.Equal(t, 612.0, record.Co2_in)
+	require.Equal(t, 590.0, record.Co2_in_24h)
+	require.Equal(t, json.Number("0"), record.Batt_25in)
+	require.Equal(t, json.Number("1"), record.Batt_cellgateway)
+	require.Nil(t, record.Extra)
+}
+
+// legacyRecord is Record without its UnmarshalJSON method, decoded
+// the way responses were before decodeRecords.
+type legacyRecord Record
+
+// historyPage returns a /devices/macaddr response of MaxLimit records,
+// each holding every Record field and two fields Record lacks.
+func historyPage(b *testing.B) []byte {
+	recordType := reflect.TypeOf(Record{})
+	last := time.Date(2023, 1, 2, 3, 5, 0, 0, time.UTC)
+	page := make([]map[string]interface{}, 0, MaxLimit)
+	for i := 0; i < MaxLimit; i++ {
+		date := last.Add(time.Duration(-i) * 5 * time.Minute)
+		fields := map[string]interface{}{"pm10_in": 12.3 + float64(i), "soilbatt1": 1.5}
+		for _, field := range Fields() {
+			structField, _ := recordType.FieldByName(field.Name)
+			switch structField.Type {
+			case reflect.TypeOf(time.Time{}):
+				fields[field.Key] = date.Format(time.RFC3339)
+			case reflect.TypeOf(json.Number("")):
+				fields[field.Key] = 1
+			}
+			switch structField.Type.Kind() {
+			case reflect.Float64:
+				fields[field.Key] = 50.25 + float64(i%50)/10
+			case reflect.Int:
+				fields[field.Key] = i % 100
+			case reflect.Int64:
+				fields[field.Key] = date.UnixMilli()
+			case reflect.String:
+				if _, ok := fields[field.Key]; !ok {
+					fields[field.Key] = "America/Chicago"
+				}
+			}
+		}
+		page = append(page, fields)
+	}
+	data, err := json.Marshal(page)
+	require.Nil(b, err)
+	return data
+}
+
+func Benchmark_decodeRecords(b *testing.B) {
+	data := historyPage(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := decodeRecords(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Benchmark_decodeRecords_Legacy decodes the same page by unmarshaling
+// it into records and again into maps, as DeviceMac used to.
+func Benchmark_decodeRecords_Legacy(b *testing.B) {
+	data := historyPage(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var records []legacyRecord
+		if err := json.Unmarshal(data, &records); err != nil {
+			b.Fatal(err)
+		}
+		var fields []map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
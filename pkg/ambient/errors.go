@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+package ambient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Sentinel errors matched by an *APIError with errors.Is.
+var (
+	// ErrRateLimited is returned when the API answers 429.
+	ErrRateLimited = errors.New("ambient: rate limited")
+	// ErrUnauthorized is returned when the API rejects the keys (401/403).
+	ErrUnauthorized = errors.New("ambient: unauthorized")
+	// ErrNotFound is returned when the API answers 404, e.g. an unknown macaddr.
+	ErrNotFound = errors.New("ambient: not found")
+	// ErrServerUnavailable is returned for 5xx answers.
+	ErrServerUnavailable = errors.New("ambient: server unavailable")
+)
+
+// maxErrorBody bounds how much of the response body
+// is quoted by APIError.Error.
+const maxErrorBody = 256
+
+// APIError describes a non-200 response from the API.
+type APIError struct {
+	// StatusCode is the HTTP status code returned.
+	StatusCode int
+	// Endpoint is the URL called, with the keys redacted.
+	Endpoint string
+	// Body is the raw response body.
+	Body []byte
+}
+
+// Error implements error.
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("ambient: %s returned %d %s", e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode))
+	if len(e.Body) > 0 {
+		body := e.Body
+		if len(body) > maxErrorBody {
+			body = body[:maxErrorBody]
+		}
+		msg += ": " + string(body)
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error matching StatusCode, if any,
+// so that errors.Is(err, ErrRateLimited) and friends work.
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode == http.StatusUnauthorized, e.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode >= 500:
+		return ErrServerUnavailable
+	}
+	return nil
+}
+
+// redacted replaces credentials in URLs and errors.
+const redacted = "REDACTED"
+
+// redactError masks the credentials in the URL carried by
+// err, as returned by net/http and net/url.
+func redactError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return &url.Error{Op: urlErr.Op, URL: redactURL(urlErr.URL), Err: urlErr.Err}
+	}
+	return err
+}
+
+// redactURL returns apiurl with the applicationKey and apiKey
+// query parameters masked.
+func redactURL(apiurl string) string {
+	u, err := url.Parse(apiurl)
+	if err != nil {
+		return redacted
+	}
+	q := u.Query()
+	for _, name := range []string{"applicationKey", "apiKey"} {
+		if q.Has(name) {
+			q.Set(name, redacted)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// UnknownFieldsError is returned by clients using WithStrictDecoding
+// when records hold fields that Record does not model.  The records
+// are returned along with it, the unknown fields in Record.Extra.
+type UnknownFieldsError struct {
+	// Fields are the names of the unknown fields, sorted.
+	Fields []string
+}
+
+func (e *UnknownFieldsError) Error() string {
+	return "ambient: unknown fields " + strings.Join(e.Fields, ", ")
+}
+
+// unknownFields returns an *UnknownFieldsError listing the
+// Extra fields of records, or nil if there are none.
+func unknownFields(records ...Record) error {
+	seen := make(map[string]bool)
+	for _, record := range records {
+		for key := range record.Extra {
+			seen[key] = true
+		}
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+	fields := make([]string, 0, len(seen))
+	for key := range seen {
+		fields = append(fields, key)
+	}
+	sort.Strings(fields)
+	return &UnknownFieldsError{Fields: fields}
+}
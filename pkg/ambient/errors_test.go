@@ -0,0 +1,91 @@
+package ambient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_APIError_Unwrap_MatchesSentinels(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		expected   error
+	}{
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrUnauthorized},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusInternalServerError, ErrServerUnavailable},
+		{http.StatusBadGateway, ErrServerUnavailable},
+		{http.StatusServiceUnavailable, ErrServerUnavailable},
+		{http.StatusGatewayTimeout, ErrServerUnavailable},
+		{http.StatusTeapot, nil},
+	}
+
+	for _, tt := range tests {
+		err := &APIError{StatusCode: tt.statusCode}
+		require.Equal(t, tt.expected, err.Unwrap(), "status %d", tt.statusCode)
+	}
+}
+
+func Test_Device_ErrorResponse_ReturnsRedactedAPIError(t *testing.T) {
+	client := getMockClient(http.StatusUnauthorized, map[string]string{"error": "apiKey-invalid"})
+
+	_, err := client.Device(NewKey("secret-application-key", "secret-api-key"))
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	require.JSONEq(t, `{"error":"apiKey-invalid"}`, string(apiErr.Body))
+	require.Contains(t, apiErr.Endpoint, "/devices")
+	require.NotContains(t, apiErr.Endpoint, "secret")
+	require.NotContains(t, err.Error(), "secret")
+	require.Contains(t, err.Error(), "apiKey-invalid")
+}
+
+func Test_Client_TransportError_RedactsKeys(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	client := NewClient(WithBaseURL(server.URL))
+	_, err := client.DeviceMac(NewKey("secret-application-key", "secret-api-key"), "mac", time.Now(), 1)
+
+	var urlErr *url.Error
+	require.True(t, errors.As(err, &urlErr))
+	require.NotContains(t, err.Error(), "secret")
+	require.Contains(t, err.Error(), "apiKey=REDACTED")
+}
+
+func Test_Client_EncodesQuery(t *testing.T) {
+	var gotQuery url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	endTime := time.Date(2023, 1, 2, 3, 4, 5, 0, time.FixedZone("CST", -6*60*60))
+	client := NewClient(WithBaseURL(server.URL))
+	_, err := client.DeviceMac(NewKey("app&key=1", "api key+2"), "mac", endTime, 288)
+
+	require.Nil(t, err)
+	require.Equal(t, "app&key=1", gotQuery.Get("applicationKey"))
+	require.Equal(t, "api key+2", gotQuery.Get("apiKey"))
+	require.Equal(t, "2023-01-02T03:04:05-06:00", gotQuery.Get("endDate"))
+	require.Equal(t, "288", gotQuery.Get("limit"))
+}
+
+func Test_redactURL_MasksKeys(t *testing.T) {
+	redactedURL := redactURL(APIEP + "/devices/mac?endDate=x&applicationKey=app&apiKey=api")
+
+	require.NotContains(t, redactedURL, "=app")
+	require.NotContains(t, redactedURL, "=api")
+	require.Contains(t, redactedURL, "applicationKey=REDACTED")
+	require.Contains(t, redactedURL, "apiKey=REDACTED")
+	require.Contains(t, redactedURL, "endDate=x")
+}
@@ -0,0 +1,236 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+package ambient
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/lrosenman/ambient/pkg/units"
+)
+
+// Field describes a Record field.
+type Field struct {
+	// Key is the name of the field in the API, e.g. "temp3f".
+	Key string
+	// Name is the name of the field in Record, e.g. "Temp3f".
+	Name string
+	// Label is a short human readable name, e.g. "Temperature 3".
+	Label string
+	// Description describes the field.
+	Description string
+	// Unit is the unit the API reports the field in,
+	// units.None for times and text.
+	Unit units.Unit
+	// Quantity is the quantity measured by the field.
+	Quantity units.Quantity
+	// Channel is the sensor channel, 1 to 10, of channel
+	// sensor fields, and 0 for the others.
+	Channel int
+	// Min and Max bound the values a working sensor reports,
+	// in Unit.  They are infinite for unbounded fields.
+	Min, Max float64
+
+	// index is the index of the field in Record and
+	// position its index in the registry.
+	index, position int
+}
+
+// Valid reports whether value lies within the valid range of f.
+func (f Field) Valid(value float64) bool {
+	return value >= f.Min && value <= f.Max
+}
+
+// fieldTemplate is an entry of the registry, possibly for numbered
+// sensors, in which case "%d" in key, label and description stands
+// for the number of the sensor.
+type fieldTemplate struct {
+	key, label, description string
+	unit                    units.Unit
+	min, max                float64
+}
+
+var inf = math.Inf(1)
+
+// fieldTemplates lists the Record fields of unnumbered sensors.
+var fieldTemplates = []fieldTemplate{
+	{"date", "Date", "Time of the observation", units.None, -inf, inf},
+	{"tz", "Time Zone", "IANA time zone of the station", units.None, -inf, inf},
+	{"baromabsin", "Absolute Pressure", "Absolute (station) pressure", units.InHg, 15, 35},
+	{"baromrelin", "Relative Pressure", "Relative (sea level) pressure", units.InHg, 25, 35},
+	{"battin", "Indoor Battery", "Indoor sensor battery, 1 OK, 0 low", units.Flag, 0, 1},
+	{"battout", "Outdoor Battery", "Outdoor sensor array battery, 1 OK, 0 low", units.Flag, 0, 1},
+	{"batt_co2", "CO2 Battery", "CO2 sensor battery, 1 OK, 0 low", units.Flag, 0, 1},
+	{"batt_lightning", "Lightning Battery", "Lightning detector battery, 0 OK, 1 low", units.Flag, 0, 1},
+	{"co2", "CO2", "Carbon dioxide concentration", units.PartsPerMillion, 0, 40000},
+	{"dailyrainin", "Daily Rain", "Rain since local midnight", units.Inch, 0, 80},
+	{"dewpoint", "Dew Point", "Outdoor dew point", units.Fahrenheit, -100, 150},
+	{"dewpointin", "Indoor Dew Point", "Indoor dew point", units.Fahrenheit, -100, 150},
+	{"eventrainin", "Event Rain", "Rain since the start of the current rain event", units.Inch, 0, 200},
+	{"feelslike", "Feels Like", "Outdoor temperature considering wind chill and heat index", units.Fahrenheit, -120, 200},
+	{"feelslikein", "Indoor Feels Like", "Indoor temperature considering heat index", units.Fahrenheit, -120, 200},
+	{"hourlyrainin", "Rain Rate", "Hourly rain rate", units.InchPerHour, 0, 40},
+	{"humidity", "Humidity", "Outdoor relative humidity", units.Percent, 0, 100},
+	{"humidityin", "Indoor Humidity", "Indoor relative humidity", units.Percent, 0, 100},
+	{"lastRain", "Last Rain", "Time rain was last detected", units.None, -inf, inf},
+	{"maxdailygust", "Max Daily Gust", "Strongest gust since local midnight", units.MilesPerHour, 0, 250},
+	{"lightning_day", "Lightning Strikes Today", "Lightning strikes since local midnight", units.Strikes, 0, inf},
+	{"lightning_distance", "Lightning Distance", "Distance of the last lightning strike", units.Kilometer, 0, 40},
+	{"lightning_hour", "Lightning Strikes Last Hour", "Lightning strikes in the last hour", units.Strikes, 0, inf},
+	{"lightning_time", "Last Lightning", "Time of the last lightning strike", units.None, -inf, inf},
+	{"pm25", "PM2.5", "Outdoor PM2.5 particulate concentration", units.MicrogramsPerCubicMeter, 0, 1000},
+	{"pm25_24h", "PM2.5 24h Average", "Outdoor PM2.5 concentration averaged over 24 hours", units.MicrogramsPerCubicMeter, 0, 1000},
+	{"monthlyrainin", "Monthly Rain", "Rain this month", units.Inch, 0, 200},
+	{"solarradiation", "Solar Radiation", "Solar radiation", units.WattsPerSquareMeter, 0, 2000},
+	{"tempf", "Outdoor Temperature", "Outdoor temperature", units.Fahrenheit, -40, 150},
+	{"tempinf", "Indoor Temperature", "Indoor temperature", units.Fahrenheit, -40, 150},
+	{"totalrainin", "Total Rain", "Rain since the console was last reset", units.Inch, 0, inf},
+	{"uv", "UV Index", "Ultraviolet radiation index", units.Index, 0, 20},
+	{"weeklyrainin", "Weekly Rain", "Rain this week", units.Inch, 0, 150},
+	{"winddir", "Wind Direction", "Instantaneous wind direction", units.Degree, 0, 360},
+	{"windgustmph", "Wind Gust", "Strongest gust in the last 10 minutes", units.MilesPerHour, 0, 250},
+	{"windgustdir", "Wind Gust Direction", "Direction of the strongest gust in the last 10 minutes", units.Degree, 0, 360},
+	{"windspeedmph", "Wind Speed", "Instantaneous wind speed", units.MilesPerHour, 0, 250},
+	{"winddir_avg2m", "Wind Direction 2m Average", "Wind direction averaged over 2 minutes", units.Degree, 0, 360},
+	{"windspdmph_avg2m", "Wind Speed 2m Average", "Wind speed averaged over 2 minutes", units.MilesPerHour, 0, 250},
+	{"winddir_avg10m", "Wind Direction 10m Average", "Wind direction averaged over 10 minutes", units.Degree, 0, 360},
+	{"windspdmph_avg10m", "Wind Speed 10m Average", "Wind speed averaged over 10 minutes", units.MilesPerHour, 0, 250},
+	{"yearlyrainin", "Yearly Rain", "Rain this year", units.Inch, 0, 1000},
+	{"aqi_pm25_in", "Indoor PM2.5 AQI", "Indoor PM2.5 air quality index", units.AQI, 0, 500},
+	{"aqi_pm25_in_24h", "Indoor PM2.5 24h AQI", "Indoor PM2.5 air quality index averaged over 24 hours", units.AQI, 0, 500},
+	{"aqi_pm25", "PM2.5 AQI", "Outdoor PM2.5 air quality index", units.AQI, 0, 500},
+	{"aqi_pm25_24h", "PM2.5 24h AQI", "Outdoor PM2.5 air quality index averaged over 24 hours", units.AQI, 0, 500},
+	{"pm25_in", "Indoor PM2.5", "Indoor PM2.5 particulate concentration", units.MicrogramsPerCubicMeter, 0, 1000},
+	{"pm25_in_24h", "Indoor PM2.5 24h Average", "Indoor PM2.5 concentration averaged over 24 hours", units.MicrogramsPerCubicMeter, 0, 1000},
+	{"co2_in", "Indoor CO2", "Indoor carbon dioxide concentration", units.PartsPerMillion, 0, 40000},
+	{"co2_in_24h", "Indoor CO2 24h Average", "Indoor carbon dioxide concentration averaged over 24 hours", units.PartsPerMillion, 0, 40000},
+	{"batt_25", "PM2.5 Battery", "Outdoor PM2.5 sensor battery, 1 OK, 0 low", units.Flag, 0, 1},
+	{"batt_25in", "Indoor PM2.5 Battery", "Indoor PM2.5 sensor battery, 1 OK, 0 low", units.Flag, 0, 1},
+	{"batt_cellgateway", "Cellular Gateway Battery", "Cellular gateway battery, 1 OK, 0 low", units.Flag, 0, 1},
+	{"dateutc", "Date (ms)", "Time of the observation in milliseconds since the Unix epoch", units.None, -inf, inf},
+}
+
+// leakTemplates lists the fields of leak detectors 1 to 4.
+var leakTemplates = []fieldTemplate{
+	{"leak%d", "Leak %d", "Leak detector %d state, 0 dry, 1 leak, 2 offline", units.Flag, 0, 2},
+	{"batt_leak%d", "Leak Battery %d", "Leak detector %d battery, 0 OK, 1 low", units.Flag, 0, 1},
+}
+
+// channelTemplates lists the fields of channel sensors 1 to 10.
+var channelTemplates = []fieldTemplate{
+	{"batt%d", "Battery %d", "Sensor %d battery, 0 OK, 1 low", units.Flag, 0, 1},
+	{"dewpoint%d", "Dew Point %d", "Sensor %d dew point", units.Fahrenheit, -100, 150},
+	{"feelslike%d", "Feels Like %d", "Sensor %d temperature considering heat index", units.Fahrenheit, -120, 200},
+	{"humidity%d", "Humidity %d", "Sensor %d relative humidity", units.Percent, 0, 100},
+	{"relay%d", "Relay %d", "Relay %d state, 1 on, 0 off", units.Flag, 0, 1},
+	{"soiltemp%df", "Soil Temperature %d", "Soil sensor %d temperature", units.Fahrenheit, -40, 150},
+	{"soilhum%d", "Soil Moisture %d", "Soil sensor %d moisture", units.Percent, 0, 100},
+	{"temp%df", "Temperature %d", "Sensor %d temperature", units.Fahrenheit, -40, 150},
+}
+
+// registry holds every Record field in Record order, and
+// registryIndex its position in registry by lower case
+// API and Go name.
+var registry, registryIndex = buildRegistry()
+
+func buildRegistry() ([]Field, map[string]int) {
+	byKey := make(map[string]Field)
+	// add registers template for sensor number, which is
+	// a channel when channel is set.
+	add := func(template fieldTemplate, number int, channel bool) {
+		n := strconv.Itoa(number)
+		field := Field{
+			Key:         strings.Replace(template.key, "%d", n, 1),
+			Label:       strings.Replace(template.label, "%d", n, 1),
+			Description: strings.Replace(template.description, "%d", n, 1),
+			Unit:        template.unit,
+			Quantity:    template.unit.Quantity(),
+			Min:         template.min,
+			Max:         template.max,
+		}
+		if field.Unit == units.None {
+			field.Min, field.Max = math.Inf(-1), inf
+		}
+		if channel {
+			field.Channel = number
+		}
+		byKey[strings.ToLower(field.Key)] = field
+	}
+	for _, template := range fieldTemplates {
+		add(template, 0, false)
+	}
+	for _, template := range channelTemplates {
+		for channel := 1; channel <= MaxChannel; channel++ {
+			add(template, channel, true)
+		}
+	}
+	// Leak detectors are numbered apart from channel sensors.
+	for _, template := range leakTemplates {
+		for n := 1; n <= 4; n++ {
+			add(template, n, false)
+		}
+	}
+
+	recordType := reflect.TypeOf(Record{})
+	fields := make([]Field, 0, recordType.NumField())
+	index := make(map[string]int, 2*recordType.NumField())
+	for i := 0; i < recordType.NumField(); i++ {
+		name := recordType.Field(i).Name
+		if !isAPIField(recordType.Field(i)) {
+			continue
+		}
+		field, ok := byKey[strings.ToLower(name)]
+		if !ok {
+			panic("ambient: Record field " + name + " missing from the field registry")
+		}
+		field.Name, field.index, field.position = name, i, len(fields)
+		index[strings.ToLower(field.Key)] = len(fields)
+		index[strings.ToLower(name)] = len(fields)
+		fields = append(fields, field)
+	}
+	if len(fields) > maxRecordFields {
+		panic("ambient: Record has more fields than presence can track")
+	}
+	return fields, index
+}
+
+// isAPIField reports whether the Record field f holds an API field.
+func isAPIField(f reflect.StructField) bool {
+	return f.IsExported() && f.Tag.Get("json") != "-"
+}
+
+// Fields returns the description of every Record field, in Record order.
+func Fields() []Field {
+	return append([]Field(nil), registry...)
+}
+
+// LookupField returns the description of a Record field given
+// by its API or Go name, ignoring case.
+func LookupField(name string) (Field, bool) {
+	i, ok := registryIndex[strings.ToLower(name)]
+	if !ok {
+		return Field{}, false
+	}
+	return registry[i], true
+}
+
+// ChannelFields returns the fields of channel sensor channel.
+func ChannelFields(channel int) []Field {
+	var fields []Field
+	for _, field := range registry {
+		if channel != 0 && field.Channel == channel {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// value returns the value of f in record.
+func (f Field) value(record Record) interface{} {
+	return reflect.ValueOf(record).Field(f.index).Interface()
+}
@@ -0,0 +1,89 @@
+package ambient
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/lrosenman/ambient/pkg/units"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Fields_CoverRecordInOrder(t *testing.T) {
+	fields := Fields()
+
+	recordType := reflect.TypeOf(Record{})
+	var names []string
+	for i := 0; i < recordType.NumField(); i++ {
+		if isAPIField(recordType.Field(i)) {
+			names = append(names, recordType.Field(i).Name)
+		}
+	}
+	require.Len(t, fields, len(names))
+	for i, field := range fields {
+		require.Equal(t, names[i], field.Name)
+		require.NotEmpty(t, field.Label, field.Name)
+		require.NotEmpty(t, field.Description, field.Name)
+		require.LessOrEqual(t, field.Min, field.Max, field.Name)
+	}
+}
+
+func Test_LookupField(t *testing.T) {
+	field, ok := LookupField("temp3f")
+	require.True(t, ok)
+	require.Equal(t, Field{
+		Key:         "temp3f",
+		Name:        "Temp3f",
+		Label:       "Temperature 3",
+		Description: "Sensor 3 temperature",
+		Unit:        units.Fahrenheit,
+		Quantity:    units.Temperature,
+		Channel:     3,
+		Min:         -40,
+		Max:         150,
+		index:       field.index,
+		position:    field.position,
+	}, field)
+
+	byName, ok := LookupField("Batt_co2")
+	require.True(t, ok)
+	require.Equal(t, "batt_co2", byName.Key)
+	require.Equal(t, units.Flag, byName.Unit)
+	require.Zero(t, byName.Channel)
+
+	lastRain, ok := LookupField("lastRain")
+	require.True(t, ok)
+	require.Equal(t, "LastRain", lastRain.Name)
+	require.Equal(t, units.None, lastRain.Unit)
+
+	leak, ok := LookupField("leak1")
+	require.True(t, ok)
+	require.Zero(t, leak.Channel)
+
+	_, ok = LookupField("leak5")
+	require.False(t, ok)
+}
+
+func Test_Field_Valid(t *testing.T) {
+	humidity, _ := LookupField("humidity")
+	require.True(t, humidity.Valid(0))
+	require.True(t, humidity.Valid(100))
+	require.False(t, humidity.Valid(101))
+
+	strikes, _ := LookupField("lightning_day")
+	require.True(t, strikes.Valid(math.MaxInt32))
+	require.False(t, strikes.Valid(-1))
+}
+
+func Test_ChannelFields(t *testing.T) {
+	fields := ChannelFields(10)
+
+	keys := make([]string, 0, len(fields))
+	for _, field := range fields {
+		require.Equal(t, 10, field.Channel)
+		keys = append(keys, field.Key)
+	}
+	require.ElementsMatch(t, []string{"batt10", "dewpoint10", "feelslike10", "humidity10",
+		"relay10", "soiltemp10f", "soilhum10", "temp10f"}, keys)
+	require.Empty(t, ChannelFields(0))
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+package ambient
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MaxLimit is the largest limit accepted by the /devices/macaddr API.
+const MaxLimit = 288
+
+// HistoryIterator walks the history of one device from newest to
+// oldest, paging backwards through /devices/macaddr calls.
+// Records found on two pages are returned once.
+//
+//	it := client.HistoryRange(ctx, key, mac, from, to)
+//	for it.Next() {
+//		record := it.Record()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type HistoryIterator struct {
+	ctx    context.Context
+	client *Client
+	key    Key
+	mac    string
+	from   time.Time
+	to     time.Time
+
+	end      time.Time
+	page     []Record
+	pos      int
+	last     bool
+	returned time.Time
+	record   Record
+	err      error
+}
+
+// String returns a representation of the iterator with its Key
+// masked, so that it can safely be logged.
+func (it HistoryIterator) String() string {
+	return "HistoryIterator{key: " + it.key.String() + ", mac: " + it.mac +
+		", from: " + it.from.Format(time.RFC3339) + ", to: " + it.to.Format(time.RFC3339) + "}"
+}
+
+// GoString implements fmt.GoStringer, masking the Key for %#v.
+func (it HistoryIterator) GoString() string {
+	return "ambient.HistoryIterator{key:" + it.key.GoString() + ", mac:" + strconv.Quote(it.mac) +
+		", from:" + strconv.Quote(it.from.Format(time.RFC3339)) + ", to:" + strconv.Quote(it.to.Format(time.RFC3339)) + "}"
+}
+
+// HistoryRange returns an iterator over the records of macaddr dated
+// from from to to, both included, newest first.  Each page is fetched
+// through the Client, so its rate limiter and retry policy apply.
+func (c *Client) HistoryRange(ctx context.Context, key Key, macaddr string, from, to time.Time) *HistoryIterator {
+	return &HistoryIterator{
+		ctx:    ctx,
+		client: c,
+		key:    key,
+		mac:    macaddr,
+		from:   from,
+		to:     to,
+		end:    to,
+	}
+}
+
+// HistoryRange returns an iterator over the history of macaddr
+// using DefaultClient.
+func HistoryRange(ctx context.Context, key Key, macaddr string, from, to time.Time) *HistoryIterator {
+	return DefaultClient.HistoryRange(ctx, key, macaddr, from, to)
+}
+
+// Next advances to the next record, fetching a new page when needed.
+// It returns false when the history is exhausted or an error occurred.
+func (it *HistoryIterator) Next() bool {
+	for {
+		for it.pos < len(it.page) {
+			record := it.page[it.pos]
+			it.pos++
+			if record.Date.After(it.to) ||
+				(!it.returned.IsZero() && !record.Date.Before(it.returned)) {
+				continue
+			}
+			if record.Date.Before(it.from) {
+				it.finish()
+				return false
+			}
+			it.record = record
+			it.returned = record.Date
+			return true
+		}
+		if it.last || it.err != nil {
+			return false
+		}
+		if !it.fetch() {
+			return false
+		}
+	}
+}
+
+// fetch loads the page ending at it.end.
+func (it *HistoryIterator) fetch() bool {
+	resp, err := it.client.DeviceMacContext(it.ctx, it.key, it.mac, it.end, MaxLimit)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	page := resp.Record
+	sort.SliceStable(page, func(i, j int) bool {
+		return page[i].Date.After(page[j].Date)
+	})
+	it.page, it.pos = page, 0
+	if len(page) < MaxLimit {
+		it.last = true
+	}
+	if len(page) > 0 {
+		oldest := page[len(page)-1].Date
+		if !oldest.Before(it.end) {
+			// No progress; the API keeps returning the same page.
+			it.last = true
+		}
+		it.end = oldest
+	}
+	return true
+}
+
+// finish stops the iteration without error.
+func (it *HistoryIterator) finish() {
+	it.page, it.pos, it.last = nil, 0, true
+}
+
+// Record returns the current record.
+func (it *HistoryIterator) Record() Record {
+	return it.record
+}
+
+// Err returns the error, if any, that stopped the iteration.
+func (it *HistoryIterator) Err() error {
+	return it.err
+}
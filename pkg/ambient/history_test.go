@@ -0,0 +1,115 @@
+package ambient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// getHistoryServer serves count records, 5 minutes apart and ending
+// at last, from /devices/macaddr.  Like the API, endDate is inclusive.
+func getHistoryServer(t *testing.T, last time.Time, count int) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		endDate, err := time.Parse(time.RFC3339, r.URL.Query().Get("endDate"))
+		require.Nil(t, err)
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		require.Nil(t, err)
+
+		page := make([]Record, 0, limit)
+		for i := 0; i < count && len(page) < limit; i++ {
+			date := last.Add(time.Duration(-i) * 5 * time.Minute)
+			if date.After(endDate) {
+				continue
+			}
+			page = append(page, Record{Date: date, Tempf: float64(i)})
+		}
+		_, _ = w.Write([]byte(mapToJson(page)))
+	}))
+	return server, &calls
+}
+
+func collectHistory(t *testing.T, it *HistoryIterator) []Record {
+	var records []Record
+	for it.Next() {
+		records = append(records, it.Record())
+	}
+	require.Nil(t, it.Err())
+	return records
+}
+
+func Test_HistoryRange_PagesBackwardsWithoutDuplicates(t *testing.T) {
+	last := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	server, calls := getHistoryServer(t, last, 1000)
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithRateLimiter(nil))
+	from := last.Add(-2 * 24 * time.Hour)
+	records := collectHistory(t, client.HistoryRange(context.Background(), NewKey("app", "api"), "mac", from, last))
+
+	require.Len(t, records, 2*288+1)
+	require.Equal(t, last, records[0].Date.UTC())
+	require.Equal(t, from, records[len(records)-1].Date.UTC())
+	for i := 1; i < len(records); i++ {
+		require.Equal(t, 5*time.Minute, records[i-1].Date.Sub(records[i].Date))
+	}
+	require.Equal(t, int32(3), atomic.LoadInt32(calls))
+}
+
+func Test_HistoryRange_StopsAtStartOfHistory(t *testing.T) {
+	last := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	server, _ := getHistoryServer(t, last, 300)
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithRateLimiter(nil))
+	records := collectHistory(t, client.HistoryRange(context.Background(), NewKey("app", "api"), "mac", time.Time{}, last))
+
+	require.Len(t, records, 300)
+}
+
+func Test_HistoryRange_SkipsRecordsAfterTo(t *testing.T) {
+	last := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	server, _ := getHistoryServer(t, last, 100)
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithRateLimiter(nil))
+	to := last.Add(-12 * time.Minute)
+	from := last.Add(-60 * time.Minute)
+	records := collectHistory(t, client.HistoryRange(context.Background(), NewKey("app", "api"), "mac", from, to))
+
+	require.Len(t, records, 10)
+	require.Equal(t, last.Add(-15*time.Minute), records[0].Date.UTC())
+	require.Equal(t, from, records[9].Date.UTC())
+}
+
+func Test_HistoryRange_Error_StopsIteration(t *testing.T) {
+	client := getMockClient(http.StatusUnauthorized, nil)
+
+	it := client.HistoryRange(context.Background(), NewKey("app", "api"), "mac", time.Time{}, time.Now())
+
+	require.False(t, it.Next())
+	require.True(t, errors.Is(it.Err(), ErrUnauthorized))
+}
+
+func Test_HistoryIterator_String_MasksKey(t *testing.T) {
+	key := NewKey("21a439e927a84a25bb79ffe894fdd372", "78f9704baaab411a87edeed59052cbb6")
+	it := HistoryRange(context.Background(), key, "mac", time.Now().Add(-time.Hour), time.Now())
+
+	for _, format := range []string{"%s", "%v", "%+v", "%#v"} {
+		for _, value := range []interface{}{it, *it} {
+			formatted := fmt.Sprintf(format, value)
+			require.NotContains(t, formatted, "21a439e927a84a25", format)
+			require.NotContains(t, formatted, "78f9704baaab411a", format)
+			require.Contains(t, formatted, "cbb6", format)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+package ambient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Documented API limits, in requests per second.
+const (
+	APIKeyRate         = 1
+	ApplicationKeyRate = 3
+)
+
+// maxThrottle caps how far a run of 429 responses
+// pushes back the next request for a key.
+const maxThrottle = 32 * time.Second
+
+// ErrLimiterWouldBlock is returned by a fail-fast RateLimiter
+// when a request cannot be sent right away.  It matches
+// ErrRateLimited with errors.Is.
+var ErrLimiterWouldBlock = fmt.Errorf("%w: local limit reached", ErrRateLimited)
+
+// LimitMode selects what a RateLimiter does when no request
+// can be sent right away.
+type LimitMode int
+
+const (
+	// LimitBlock waits until both budgets allow the request.
+	LimitBlock LimitMode = iota
+	// LimitFailFast returns ErrLimiterWouldBlock instead of waiting.
+	LimitFailFast
+)
+
+// RateLimiter spaces requests so that neither the per-apiKey
+// nor the per-applicationKey budget is exceeded.  When the server
+// still answers 429 the affected keys are backed off further,
+// doubling on each consecutive 429.
+// A RateLimiter is safe for concurrent use and should be shared
+// by every Client that uses the same keys.
+type RateLimiter struct {
+	apiKeyInterval         time.Duration
+	applicationKeyInterval time.Duration
+	mode                   LimitMode
+
+	mu sync.Mutex
+	// next and throttle are keyed by slot, which hashes the
+	// keys so that printing the limiter does not show them.
+	next     map[string]time.Time
+	throttle map[string]time.Duration
+}
+
+// NewRateLimiter returns a RateLimiter allowing apiKeyRate requests
+// per second for each apiKey and applicationKeyRate requests per
+// second for each applicationKey.
+func NewRateLimiter(apiKeyRate, applicationKeyRate float64, mode LimitMode) *RateLimiter {
+	return &RateLimiter{
+		apiKeyInterval:         rateInterval(apiKeyRate),
+		applicationKeyInterval: rateInterval(applicationKeyRate),
+		mode:                   mode,
+		next:                   make(map[string]time.Time),
+		throttle:               make(map[string]time.Duration),
+	}
+}
+
+func rateInterval(rate float64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / rate)
+}
+
+func apiKeySlot(key Key) string {
+	return slot("apiKey", key.apiKey)
+}
+
+func applicationKeySlot(key Key) string {
+	return slot("applicationKey", key.applicationKey)
+}
+
+// slot returns the map key of the budget of secret,
+// holding a hash of it rather than secret itself.
+func slot(kind, secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return kind + ":" + hex.EncodeToString(sum[:])
+}
+
+// Wait blocks until a request for key may be sent, or returns
+// ErrLimiterWouldBlock in fail-fast mode.  It returns ctx.Err()
+// if ctx is done first.
+func (l *RateLimiter) Wait(ctx context.Context, key Key) error {
+	delay, err := l.reserve(key, time.Now())
+	if err != nil || delay <= 0 {
+		return err
+	}
+	return sleepContext(ctx, delay)
+}
+
+// reserve books the earliest slot allowed by both budgets
+// and returns how long to wait for it.
+func (l *RateLimiter) reserve(key Key, now time.Time) (time.Duration, error) {
+	apiSlot, appSlot := apiKeySlot(key), applicationKeySlot(key)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	at := now
+	if next := l.next[apiSlot]; next.After(at) {
+		at = next
+	}
+	if next := l.next[appSlot]; next.After(at) {
+		at = next
+	}
+	if at.After(now) && l.mode == LimitFailFast {
+		return 0, ErrLimiterWouldBlock
+	}
+	l.prune(now)
+	l.next[apiSlot] = at.Add(l.apiKeyInterval)
+	l.next[appSlot] = at.Add(l.applicationKeyInterval)
+	return at.Sub(now), nil
+}
+
+// prune forgets keys that have been idle long enough
+// not to matter any more.  Must be called with l.mu held.
+func (l *RateLimiter) prune(now time.Time) {
+	if len(l.next) < 1024 {
+		return
+	}
+	for slot, next := range l.next {
+		if next.Before(now) && l.throttle[slot] == 0 {
+			delete(l.next, slot)
+		}
+	}
+}
+
+// Throttled records a 429 for key.  Both budgets are pushed back
+// by at least hint, and by twice the previous back off otherwise.
+func (l *RateLimiter) Throttled(key Key, hint time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, slot := range []string{apiKeySlot(key), applicationKeySlot(key)} {
+		backoff := 2 * l.throttle[slot]
+		if backoff == 0 {
+			backoff = time.Second
+		}
+		if backoff > maxThrottle {
+			backoff = maxThrottle
+		}
+		l.throttle[slot] = backoff
+		if hint > backoff {
+			backoff = hint
+		}
+		if until := now.Add(backoff); until.After(l.next[slot]) {
+			l.next[slot] = until
+		}
+	}
+}
+
+// Succeeded resets the back off recorded by Throttled for key.
+func (l *RateLimiter) Succeeded(key Key) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.throttle, apiKeySlot(key))
+	delete(l.throttle, applicationKeySlot(key))
+}
@@ -0,0 +1,149 @@
+package ambient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RateLimiter_SameAPIKey_SpacesRequests(t *testing.T) {
+	limiter := NewRateLimiter(20, 100, LimitBlock)
+	key := NewKey("application-key", "api-key")
+	now := time.Now()
+
+	first, err := limiter.reserve(key, now)
+	require.Nil(t, err)
+	second, err := limiter.reserve(key, now)
+	require.Nil(t, err)
+	third, err := limiter.reserve(key, now)
+	require.Nil(t, err)
+
+	require.Zero(t, first)
+	require.Equal(t, 50*time.Millisecond, second)
+	require.Equal(t, 100*time.Millisecond, third)
+}
+
+func Test_RateLimiter_SharedApplicationKey_SpacesAcrossAPIKeys(t *testing.T) {
+	limiter := NewRateLimiter(1, 3, LimitBlock)
+	now := time.Now()
+
+	var delays []time.Duration
+	for _, apiKey := range []string{"one", "two", "three", "four"} {
+		delay, err := limiter.reserve(NewKey("application-key", apiKey), now)
+		require.Nil(t, err)
+		delays = append(delays, delay)
+	}
+
+	require.Equal(t, []time.Duration{0, time.Second / 3, 2 * (time.Second / 3), 3 * (time.Second / 3)}, delays)
+}
+
+func Test_RateLimiter_DifferentKeys_DoNotInterfere(t *testing.T) {
+	limiter := NewRateLimiter(1, 3, LimitBlock)
+	now := time.Now()
+
+	_, err := limiter.reserve(NewKey("application-one", "api-one"), now)
+	require.Nil(t, err)
+	delay, err := limiter.reserve(NewKey("application-two", "api-two"), now)
+	require.Nil(t, err)
+
+	require.Zero(t, delay)
+}
+
+func Test_RateLimiter_FailFast_ReturnsErrLimiterWouldBlock(t *testing.T) {
+	limiter := NewRateLimiter(1, 3, LimitFailFast)
+	key := NewKey("application-key", "api-key")
+
+	require.Nil(t, limiter.Wait(context.Background(), key))
+	err := limiter.Wait(context.Background(), key)
+
+	require.True(t, errors.Is(err, ErrLimiterWouldBlock))
+	require.True(t, errors.Is(err, ErrRateLimited))
+}
+
+func Test_RateLimiter_Wait_CancelledContext_ReturnsError(t *testing.T) {
+	limiter := NewRateLimiter(1, 3, LimitBlock)
+	key := NewKey("application-key", "api-key")
+	require.Nil(t, limiter.Wait(context.Background(), key))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := limiter.Wait(ctx, key)
+
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func Test_RateLimiter_Throttled_BacksOffUntilSucceeded(t *testing.T) {
+	limiter := NewRateLimiter(1000, 1000, LimitBlock)
+	key := NewKey("application-key", "api-key")
+
+	limiter.Throttled(key, 0)
+	first, _ := limiter.reserve(key, time.Now())
+	limiter.Throttled(key, 0)
+	limiter.Throttled(key, 0)
+	backoff := limiter.throttle[apiKeySlot(key)]
+	limiter.Succeeded(key)
+
+	require.InDelta(t, float64(time.Second), float64(first), float64(10*time.Millisecond))
+	require.Equal(t, 4*time.Second, backoff)
+	require.Empty(t, limiter.throttle)
+}
+
+func Test_RateLimiter_Throttled_HonorsHint(t *testing.T) {
+	limiter := NewRateLimiter(1000, 1000, LimitBlock)
+	key := NewKey("application-key", "api-key")
+
+	limiter.Throttled(key, 5*time.Second)
+	delay, _ := limiter.reserve(key, time.Now())
+
+	require.InDelta(t, float64(5*time.Second), float64(delay), float64(10*time.Millisecond))
+}
+
+func Test_RateLimiter_Concurrent_SpacesAllRequests(t *testing.T) {
+	limiter := NewRateLimiter(200, 1000, LimitBlock)
+	key := NewKey("application-key", "api-key")
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.Nil(t, limiter.Wait(context.Background(), key))
+		}()
+	}
+	wg.Wait()
+
+	require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+func Test_Client_TooManyRequests_ThrottlesLimiter(t *testing.T) {
+	limiter := NewRateLimiter(1000, 1000, LimitFailFast)
+	client := getMockClient(http.StatusTooManyRequests, nil)
+	WithRateLimiter(limiter)(client)
+	key := NewKey("application-key", "api-key")
+
+	_, err := client.Device(key)
+	require.True(t, errors.Is(err, ErrRateLimited))
+	_, err = client.Device(key)
+
+	require.True(t, errors.Is(err, ErrLimiterWouldBlock))
+}
+
+func Test_RateLimiter_String_HidesKeys(t *testing.T) {
+	limiter := NewRateLimiter(1000, 1000, LimitBlock)
+	key := NewKey("21a439e927a84a25bb79ffe894fdd372", "78f9704baaab411a87edeed59052cbb6")
+	require.Nil(t, limiter.Wait(context.Background(), key))
+	limiter.Throttled(key, 0)
+
+	for _, format := range []string{"%s", "%v", "%+v", "%#v"} {
+		formatted := fmt.Sprintf(format, limiter)
+		require.NotContains(t, formatted, "21a439e927a84a25", format)
+		require.NotContains(t, formatted, "78f9704baaab411a", format)
+	}
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+package ambient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// maxRecordFields is the number of Record fields presence can track.
+const maxRecordFields = 256
+
+// presence records which Record fields a decoded response held,
+// by registry position.
+type presence struct {
+	decoded bool
+	bits    [maxRecordFields / 64]uint64
+}
+
+func (p *presence) set(position int) {
+	p.bits[position/64] |= 1 << (position % 64)
+}
+
+func (p presence) get(position int) bool {
+	return p.bits[position/64]&(1<<(position%64)) != 0
+}
+
+// UnmarshalJSON decodes a record of the API, noting which fields it holds.
+func (record *Record) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var decoder recordDecoder
+	_, err := decoder.decode(json.NewDecoder(bytes.NewReader(data)), record)
+	return err
+}
+
+// Has reports whether record holds field, given by its API or Go name,
+// telling a sensor reading zero from a missing sensor.  For a record
+// that was not decoded from JSON, such as one built in code, Has
+// reports whether field is non-zero, unless SetPresent marked it.
+func (record Record) Has(field string) bool {
+	f, ok := LookupField(field)
+	return ok && record.has(f)
+}
+
+// SetPresent marks fields, given by their API or Go names, as held
+// by record, so that Has reports them even when zero, as it does for
+// a record decoded from JSON.  On a record built in code, the fields
+// that are non-zero are marked as well.  It returns an error, marking
+// nothing, if a field is unknown.
+func (record *Record) SetPresent(fields ...string) error {
+	positions := make([]int, 0, len(fields))
+	for _, name := range fields {
+		field, ok := LookupField(name)
+		if !ok {
+			return fmt.Errorf("ambient: unknown field %q", name)
+		}
+		positions = append(positions, field.position)
+	}
+	if !record.present.decoded {
+		for _, field := range registry {
+			if record.has(field) {
+				record.present.set(field.position)
+			}
+		}
+		record.present.decoded = true
+	}
+	for _, position := range positions {
+		record.present.set(position)
+	}
+	return nil
+}
+
+func (record Record) has(field Field) bool {
+	if record.present.decoded {
+		return record.present.get(field.position)
+	}
+	switch value := field.value(record).(type) {
+	case float64:
+		return value != 0
+	case int:
+		return value != 0
+	case int64:
+		return value != 0
+	case json.Number:
+		return value != ""
+	case string:
+		return value != ""
+	case interface{ IsZero() bool }:
+		return !value.IsZero()
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package ambient
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Record_Has_DecodedRecord(t *testing.T) {
+	var record Record
+
+	err := json.Unmarshal([]byte(`{"date":"2023-01-02T03:04:05.000Z","tempf":0,"uv":null,"Temp1F":50,"leak5":0}`), &record)
+
+	require.Nil(t, err)
+	require.True(t, record.Has("date"))
+	require.True(t, record.Has("tempf"))
+	require.True(t, record.Has("Tempf"))
+	require.True(t, record.Has("temp1f"))
+	require.False(t, record.Has("uv"))
+	require.False(t, record.Has("temp5f"))
+	require.False(t, record.Has("leak5"))
+}
+
+func Test_Record_Has_TypeMismatch_IsNotPresent(t *testing.T) {
+	var record Record
+
+	err := json.Unmarshal([]byte(`{"humidity":41.5,"tempf":50}`), &record)
+
+	require.NotNil(t, err)
+	require.Equal(t, 50.0, record.Tempf)
+	require.True(t, record.Has("tempf"))
+	require.False(t, record.Has("humidity"))
+}
+
+func Test_Record_Has_BuiltRecord_ReportsNonZero(t *testing.T) {
+	record := Record{Tempf: 50, Batt1: "0", Date: time.Unix(0, 0)}
+
+	require.True(t, record.Has("tempf"))
+	require.True(t, record.Has("batt1"))
+	require.True(t, record.Has("date"))
+	require.False(t, record.Has("uv"))
+	require.False(t, record.Has("tz"))
+	require.False(t, record.Has("nosuchfield"))
+}
+
+func Test_Record_SetPresent_ZeroField_IsPresent(t *testing.T) {
+	record := Record{Tempf: 50, Date: time.Unix(0, 0)}
+
+	require.Nil(t, record.SetPresent("solarradiation", "Dailyrainin"))
+
+	require.True(t, record.Has("solarradiation"))
+	require.True(t, record.Has("dailyrainin"))
+	require.True(t, record.Has("tempf"))
+	require.True(t, record.Has("date"))
+	require.False(t, record.Has("uv"))
+	require.False(t, record.Has("humidity"))
+}
+
+func Test_Record_SetPresent_UnknownField_ReturnsError(t *testing.T) {
+	record := Record{Tempf: 50}
+
+	require.NotNil(t, record.SetPresent("uv", "nosuchfield"))
+
+	require.False(t, record.Has("uv"))
+}
+
+func Test_Record_SetPresent_DecodedRecord(t *testing.T) {
+	var record Record
+	require.Nil(t, json.Unmarshal([]byte(`{"tempf":0}`), &record))
+
+	require.Nil(t, record.SetPresent("uv"))
+
+	require.True(t, record.Has("tempf"))
+	require.True(t, record.Has("uv"))
+	require.False(t, record.Has("humidity"))
+}
+
+func Test_Record_Channel_ZeroReading_IsPresent(t *testing.T) {
+	var record Record
+	require.Nil(t, json.Unmarshal([]byte(`{"temp2f":0,"relay2":0}`), &record))
+
+	channel, ok := record.Channel(2)
+
+	require.True(t, ok)
+	require.True(t, channel.HasTemp)
+	require.True(t, channel.HasRelay)
+	require.False(t, channel.HasHumidity)
+}
+
+func Test_DeviceMac_FillsPresence(t *testing.T) {
+	client := getMockClient(http.StatusOK, json.RawMessage(`[{"date":"2023-01-02T03:04:05.000Z","tempf":0,"humidity":40}]`))
+
+	results, err := client.DeviceMac(NewKey("app", "api"), "00:0E:C6:10:01:86", time.Now(), 1)
+
+	require.Nil(t, err)
+	require.Len(t, results.Record, 1)
+	require.True(t, results.Record[0].Has("tempf"))
+	require.False(t, results.Record[0].Has("uv"))
+}
+
+func Test_Device_FillsPresence(t *testing.T) {
+	client := getMockClient(http.StatusOK, json.RawMessage(`[{"macAddress":"00:0E:C6:10:01:86","lastData":{"uv":0}}]`))
+
+	results, err := client.Device(NewKey("app", "api"))
+
+	require.Nil(t, err)
+	require.Len(t, results.DeviceRecord, 1)
+	require.True(t, results.DeviceRecord[0].LastData.Has("uv"))
+	require.False(t, results.DeviceRecord[0].LastData.Has("tempf"))
+}
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+package ambient
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryDecider reports whether a request should be retried after
+// its attempt-th attempt (starting at 1) ended with resp and err.
+// resp is nil when err is a transport error.
+type RetryDecider func(attempt int, resp *http.Response, err error) bool
+
+// RetryPolicy controls how a Client retries failed requests.
+// Only idempotent requests are ever retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the
+	// first one.  Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry; it doubles
+	// for each further retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts.
+	MaxDelay time.Duration
+	// Jitter is the fraction, between 0 and 1, of each delay
+	// that is randomized.
+	Jitter float64
+	// MaxElapsed bounds the time spent on all attempts of one call.
+	// No retry is started that would end after it.  Zero means no bound.
+	MaxElapsed time.Duration
+	// Decider decides which failures are retried.
+	// It defaults to DefaultRetryDecider.
+	Decider RetryDecider
+}
+
+// DefaultRetryPolicy returns a policy making up to 4 attempts,
+// backing off from 1s to 30s with 20% jitter within 2 minutes.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   1 * time.Second,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.2,
+		MaxElapsed:  2 * time.Minute,
+	}
+}
+
+// DefaultRetryDecider retries 429, 502 and 503 responses.
+func DefaultRetryDecider(_ int, resp *http.Response, _ error) bool {
+	if resp == nil {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+// shouldRetry reports whether the attempt-th attempt of a
+// method request should be followed by another one.
+func (p *RetryPolicy) shouldRetry(method string, attempt int, resp *http.Response, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts || !idempotent(method) {
+		return false
+	}
+	decider := p.Decider
+	if decider == nil {
+		decider = DefaultRetryDecider
+	}
+	return decider(attempt, resp, err)
+}
+
+// delay returns how long to wait after the attempt-th attempt.
+// A Retry-After header on resp takes precedence over the back off.
+func (p *RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if retryAfter := parseRetryAfter(resp, time.Now()); retryAfter > 0 {
+		return retryAfter
+	}
+	d := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		d = time.Duration(float64(d) * (1 - jitter*rand.Float64()))
+	}
+	return d
+}
+
+// idempotent reports whether requests using method may be repeated.
+func idempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter returns the delay requested by the Retry-After
+// header of resp, given either in seconds or as an HTTP date.
+func parseRetryAfter(resp *http.Response, now time.Time) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,171 @@
+package ambient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func getFlakyServer(failures int32, statusCode int, header http.Header) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			for name, values := range header {
+				w.Header()[name] = values
+			}
+			w.WriteHeader(statusCode)
+			return
+		}
+		_, _ = w.Write([]byte(mapToJson([]*DeviceRecord{getValidDeviceRecord()})))
+	}))
+	return server, &calls
+}
+
+func getFastRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+}
+
+func Test_Client_RetryPolicy_RetriesUntilSuccess(t *testing.T) {
+	server, calls := getFlakyServer(2, http.StatusServiceUnavailable, nil)
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithRateLimiter(nil), WithRetryPolicy(getFastRetryPolicy()))
+	devices, err := client.Device(NewKey("application-key", "api-key"))
+
+	require.Nil(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(calls))
+	require.Equal(t, http.StatusOK, devices.HTTPResponseCode)
+	require.Len(t, devices.DeviceRecord, 1)
+}
+
+func Test_Client_RetryPolicy_GivesUpAfterMaxAttempts(t *testing.T) {
+	server, calls := getFlakyServer(10, http.StatusBadGateway, nil)
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithRateLimiter(nil), WithRetryPolicy(getFastRetryPolicy()))
+	_, err := client.Device(NewKey("application-key", "api-key"))
+
+	require.True(t, errors.Is(err, ErrServerUnavailable))
+	require.Equal(t, int32(4), atomic.LoadInt32(calls))
+}
+
+func Test_Client_RetryPolicy_DoesNotRetryOtherErrors(t *testing.T) {
+	server, calls := getFlakyServer(10, http.StatusUnauthorized, nil)
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithRateLimiter(nil), WithRetryPolicy(getFastRetryPolicy()))
+	_, err := client.Device(NewKey("application-key", "api-key"))
+
+	require.True(t, errors.Is(err, ErrUnauthorized))
+	require.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+func Test_Client_NoRetryPolicy_DoesNotRetry(t *testing.T) {
+	server, calls := getFlakyServer(10, http.StatusTooManyRequests, nil)
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithRateLimiter(nil))
+	_, err := client.Device(NewKey("application-key", "api-key"))
+
+	require.True(t, errors.Is(err, ErrRateLimited))
+	require.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+func Test_Client_RetryPolicy_CustomDecider(t *testing.T) {
+	server, calls := getFlakyServer(1, http.StatusInternalServerError, nil)
+	defer server.Close()
+
+	policy := getFastRetryPolicy()
+	var attempts []int
+	policy.Decider = func(attempt int, resp *http.Response, err error) bool {
+		attempts = append(attempts, attempt)
+		return resp != nil && resp.StatusCode == http.StatusInternalServerError
+	}
+	client := NewClient(WithBaseURL(server.URL), WithRateLimiter(nil), WithRetryPolicy(policy))
+	_, err := client.Device(NewKey("application-key", "api-key"))
+
+	require.Nil(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(calls))
+	require.Equal(t, []int{1, 2}, attempts)
+}
+
+func Test_Client_RetryPolicy_MaxElapsedStopsRetries(t *testing.T) {
+	server, calls := getFlakyServer(10, http.StatusServiceUnavailable, nil)
+	defer server.Close()
+
+	policy := &RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxElapsed: 100 * time.Millisecond}
+	client := NewClient(WithBaseURL(server.URL), WithRateLimiter(nil), WithRetryPolicy(policy))
+	_, err := client.Device(NewKey("application-key", "api-key"))
+
+	require.True(t, errors.Is(err, ErrServerUnavailable))
+	require.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+func Test_Client_RetryPolicy_HonorsRetryAfter(t *testing.T) {
+	server, calls := getFlakyServer(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}})
+	defer server.Close()
+
+	policy := getFastRetryPolicy()
+	client := NewClient(WithBaseURL(server.URL), WithRateLimiter(nil), WithRetryPolicy(policy))
+	start := time.Now()
+	_, err := client.Device(NewKey("application-key", "api-key"))
+
+	require.Nil(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(calls))
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func Test_RetryPolicy_Delay_BacksOffExponentiallyWithCap(t *testing.T) {
+	policy := &RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	require.Equal(t, time.Second, policy.delay(1, nil))
+	require.Equal(t, 2*time.Second, policy.delay(2, nil))
+	require.Equal(t, 4*time.Second, policy.delay(3, nil))
+	require.Equal(t, 5*time.Second, policy.delay(4, nil))
+	require.Equal(t, 5*time.Second, policy.delay(40, nil))
+}
+
+func Test_RetryPolicy_Delay_AppliesJitter(t *testing.T) {
+	policy := &RetryPolicy{BaseDelay: time.Second, Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		delay := policy.delay(1, nil)
+		require.GreaterOrEqual(t, delay, 500*time.Millisecond)
+		require.LessOrEqual(t, delay, time.Second)
+	}
+}
+
+func Test_RetryPolicy_ShouldRetry_OnlyIdempotentMethods(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable}
+
+	require.True(t, policy.shouldRetry(http.MethodGet, 1, resp, nil))
+	require.False(t, policy.shouldRetry(http.MethodPost, 1, resp, nil))
+	require.False(t, policy.shouldRetry(http.MethodGet, policy.MaxAttempts, resp, nil))
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"-1", 0},
+		{now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second},
+		{now.Add(-10 * time.Second).Format(http.TimeFormat), 0},
+		{"soon", 0},
+	}
+
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("Retry-After", tt.value)
+		require.Equal(t, tt.expected, parseRetryAfter(resp, now), "Retry-After: %q", tt.value)
+	}
+}
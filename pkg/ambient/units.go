@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+package ambient
+
+import (
+	"encoding/json"
+
+	"github.com/lrosenman/ambient/pkg/units"
+)
+
+// FieldUnit returns the unit the API reports field in, given by
+// its API name such as "tempf", and whether field is a numeric
+// Record field.
+func FieldUnit(field string) (units.Unit, bool) {
+	f, ok := LookupField(field)
+	return f.Unit, ok && f.Unit != units.None
+}
+
+// View presents the numeric fields of a Record in a UnitSystem.
+type View struct {
+	record Record
+	system units.UnitSystem
+}
+
+// In returns a view of the record with its measurements
+// converted to system.
+func (record Record) In(system units.UnitSystem) View {
+	return View{record: record, system: system}
+}
+
+// System returns the UnitSystem of the view.
+func (v View) System() units.UnitSystem {
+	return v.system
+}
+
+// Value returns field, given by its API name such as "tempf",
+// converted to the view's UnitSystem, and whether field is a
+// numeric Record field held by the record, as reported by Has.
+func (v View) Value(field string) (units.Value, bool) {
+	number, ok := v.record.Number(field)
+	if !ok {
+		return units.Value{}, false
+	}
+	unit, _ := FieldUnit(field)
+	return units.Value{Value: number, Unit: unit}.In(v.system), true
+}
+
+// Number returns field, given by its API name such as "tempf", in the
+// unit the API reports it in, and whether field is a numeric Record
+// field held by the record, as reported by Has.
+func (record Record) Number(field string) (float64, bool) {
+	f, ok := LookupField(field)
+	if !ok || f.Unit == units.None || !record.has(f) {
+		return 0, false
+	}
+	switch value := f.value(record).(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case json.Number:
+		number, err := value.Float64()
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// Values returns every numeric field held by the record,
+// converted to the view's UnitSystem, by API name.
+func (v View) Values() map[string]units.Value {
+	values := make(map[string]units.Value, len(registry))
+	for _, field := range registry {
+		if value, ok := v.Value(field.Key); ok {
+			values[field.Key] = value
+		}
+	}
+	return values
+}
@@ -0,0 +1,80 @@
+package ambient
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/units"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FieldUnit_EveryNumericField(t *testing.T) {
+	recordType := reflect.TypeOf(Record{})
+	for i := 0; i < recordType.NumField(); i++ {
+		field := recordType.Field(i)
+		if !isAPIField(field) || field.Name == "Dateutc" {
+			continue
+		}
+		switch field.Type {
+		case reflect.TypeOf(time.Time{}), reflect.TypeOf(""):
+			continue
+		}
+		_, ok := FieldUnit(field.Name)
+		require.True(t, ok, field.Name)
+	}
+}
+
+func Test_Record_In_Metric(t *testing.T) {
+	record := Record{Tempf: 212, Baromrelin: 29.92, Dailyrainin: 1, Windspeedmph: 10, Humidity: 41, Lightning_distance: 10}
+
+	view := record.In(units.Metric)
+
+	require.Equal(t, units.Metric, view.System())
+	tempf, ok := view.Value("tempf")
+	require.True(t, ok)
+	require.Equal(t, units.Value{Value: 100, Unit: units.Celsius}, tempf)
+	barom, _ := view.Value("baromrelin")
+	require.Equal(t, "1013.2 hPa", barom.String())
+	rain, _ := view.Value("dailyrainin")
+	require.Equal(t, "25.4 mm", rain.String())
+	wind, _ := view.Value("Windspeedmph")
+	require.Equal(t, "16.1 km/h", wind.String())
+	humidity, _ := view.Value("humidity")
+	require.Equal(t, units.Value{Value: 41, Unit: units.Percent}, humidity)
+	distance, _ := view.Value("lightning_distance")
+	require.Equal(t, units.Value{Value: 10, Unit: units.Kilometer}, distance)
+	_, ok = view.Value("tz")
+	require.False(t, ok)
+	_, ok = view.Value("uv")
+	require.False(t, ok)
+}
+
+func Test_Record_In_UK(t *testing.T) {
+	record := Record{Tempf: 50, Windspeedmph: 10, Batt1: "1"}
+
+	values := record.In(units.UK).Values()
+
+	require.Equal(t, "10.0 °C", values["tempf"].String())
+	require.Equal(t, "10.0 mph", values["windspeedmph"].String())
+	require.Equal(t, units.Value{Value: 1, Unit: units.Flag}, values["batt1"])
+	require.NotContains(t, values, "date")
+}
+
+func Test_Record_Number(t *testing.T) {
+	record := Record{Tempf: 68.5, Humidity: 40, Batt1: "1"}
+
+	tempf, ok := record.Number("tempf")
+	require.True(t, ok)
+	require.Equal(t, 68.5, tempf)
+	humidity, ok := record.Number("Humidity")
+	require.True(t, ok)
+	require.Equal(t, 40.0, humidity)
+	batt, ok := record.Number("batt1")
+	require.True(t, ok)
+	require.Equal(t, 1.0, batt)
+	_, ok = record.Number("uv")
+	require.False(t, ok)
+	_, ok = record.Number("tz")
+	require.False(t, ok)
+}
@@ -0,0 +1,241 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+// Package astro computes the position of the sun and moon above a
+// station from its LocationInfo: sunrise and sunset, solar elevation,
+// day length, moon phase and the irradiance expected under a clear sky,
+// against which the Solarradiation of a Record can be compared.
+//
+// The sun is placed with the NOAA solar calculator algorithm, accurate
+// to about a minute for dates between 1901 and 2099.  The moon phase is
+// the mean phase, within about a day of the true one.
+package astro
+
+import (
+	"math"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+)
+
+const (
+	// SolarConstant is the irradiance of the sun at
+	// one astronomical unit, in W/m².
+	SolarConstant = 1361
+	// SynodicMonth is the mean time between two new moons, in days.
+	SynodicMonth = 29.530588853
+	// MinClearnessElevation is the solar elevation, in degrees, under
+	// which Clearness is not computed, the irradiance of a low sun
+	// being too sensitive to the surroundings of the sensor.
+	MinClearnessElevation = 10
+	// horizon is the solar elevation at sunrise and sunset, in
+	// degrees, allowing for refraction and the radius of the sun.
+	horizon = -0.833
+)
+
+// newMoon is a new moon, the start of lunation 0.
+var newMoon = time.Date(2000, time.January, 6, 18, 14, 0, 0, time.UTC)
+
+// Sun is the sun as seen from a location.
+type Sun struct {
+	// Elevation is the angle of the sun above the horizon and Azimuth
+	// its direction, clockwise from north, in degrees.
+	Elevation, Azimuth float64
+	// Sunrise, Noon and Sunset are those of the calendar day of the
+	// time the sun was computed for.  Sunrise and Sunset are zero
+	// during a polar day or night.
+	Sunrise, Noon, Sunset time.Time
+	// DayLength is the time between sunrise and sunset,
+	// 24 hours during a polar day and 0 during a polar night.
+	DayLength time.Duration
+}
+
+// Daylight reports whether the sun is above the horizon.
+func (s Sun) Daylight() bool {
+	return s.Elevation > horizon
+}
+
+// Moon is the phase of the moon.
+type Moon struct {
+	// Age is the time since the last new moon, in days.
+	Age float64
+	// Phase is the fraction of the lunation elapsed: 0 at new moon,
+	// 0.25 at first quarter, 0.5 at full moon and 0.75 at last quarter.
+	Phase float64
+	// Illumination is the fraction of the disk lit, from 0 to 1.
+	Illumination float64
+}
+
+// moonPhases are the names of the phases, each an eighth of a lunation
+// centered on new moon, first quarter, full moon and last quarter.
+var moonPhases = [...]string{
+	"New Moon", "Waxing Crescent", "First Quarter", "Waxing Gibbous",
+	"Full Moon", "Waning Gibbous", "Last Quarter", "Waning Crescent",
+}
+
+// Name returns the name of the phase of the moon, e.g. "Waxing Gibbous".
+func (m Moon) Name() string {
+	return moonPhases[int(math.Floor(m.Phase*8+0.5))%8]
+}
+
+// Sky is the astronomical context of an observation.
+type Sky struct {
+	Sun  Sun
+	Moon Moon
+	// ClearSkyIrradiance is the global irradiance expected on a
+	// horizontal surface under a clear sky, in W/m².
+	ClearSkyIrradiance float64
+}
+
+// At returns the sky above location at t.
+func At(location ambient.LocationInfo, t time.Time) Sky {
+	return Sky{
+		Sun:                SunAt(location, t),
+		Moon:               MoonAt(t),
+		ClearSkyIrradiance: ClearSkyIrradiance(location, t),
+	}
+}
+
+// SunAt returns the sun above location at t.  The day of Sunrise, Noon
+// and Sunset is the calendar day of t in its time.Location.
+func SunAt(location ambient.LocationInfo, t time.Time) Sun {
+	lat, lon := location.Coords.Lat, location.Coords.Lon
+	sun := Sun{}
+	sun.Elevation, sun.Azimuth = position(lat, lon, t)
+
+	year, month, day := t.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	// Solar noon, refined with the equation of time at noon.
+	sun.Noon = midnight.Add(minutes(720 - 4*lon))
+	for i := 0; i < 2; i++ {
+		_, eqTime := solar(sun.Noon)
+		sun.Noon = midnight.Add(minutes(720 - 4*lon - eqTime))
+	}
+	sun.Noon = sun.Noon.In(t.Location())
+
+	declination, _ := solar(sun.Noon)
+	cosHourAngle := (math.Sin(radians(horizon)) - math.Sin(radians(lat))*math.Sin(declination)) /
+		(math.Cos(radians(lat)) * math.Cos(declination))
+	switch {
+	case cosHourAngle < -1:
+		sun.DayLength = 24 * time.Hour
+	case cosHourAngle > 1:
+	default:
+		halfDay := minutes(4 * degrees(math.Acos(cosHourAngle)))
+		sun.Sunrise = sun.Noon.Add(-halfDay)
+		sun.Sunset = sun.Noon.Add(halfDay)
+		sun.DayLength = sun.Sunset.Sub(sun.Sunrise)
+	}
+	return sun
+}
+
+// MoonAt returns the phase of the moon at t.
+func MoonAt(t time.Time) Moon {
+	days := t.Sub(newMoon).Hours() / 24
+	age := math.Mod(days, SynodicMonth)
+	if age < 0 {
+		age += SynodicMonth
+	}
+	phase := age / SynodicMonth
+	return Moon{
+		Age:          age,
+		Phase:        phase,
+		Illumination: (1 - math.Cos(2*math.Pi*phase)) / 2,
+	}
+}
+
+// ClearSkyIrradiance returns the global irradiance expected on a
+// horizontal surface at location under a clear sky at t, in W/m², with
+// the clear-sky transmittance of FAO-56 equation 37, which accounts for
+// the elevation of location.
+func ClearSkyIrradiance(location ambient.LocationInfo, t time.Time) float64 {
+	elevation, _ := position(location.Coords.Lat, location.Coords.Lon, t)
+	if elevation <= 0 {
+		return 0
+	}
+	distance := sunDistance(julianCentury(t))
+	extraterrestrial := SolarConstant / (distance * distance) * math.Sin(radians(elevation))
+	return (0.75 + 2e-5*location.Elevation) * extraterrestrial
+}
+
+// Clearness returns the ratio of the Solarradiation of record to the
+// ClearSkyIrradiance at location and record.Date, about 1 under a clear
+// sky and lower under clouds.  A sensor reading low on clear days needs
+// cleaning.  It reports false when record lacks Solarradiation or the
+// sun is lower than MinClearnessElevation.
+func Clearness(location ambient.LocationInfo, record ambient.Record) (float64, bool) {
+	if !record.Has("solarradiation") {
+		return 0, false
+	}
+	elevation, _ := position(location.Coords.Lat, location.Coords.Lon, record.Date)
+	if elevation < MinClearnessElevation {
+		return 0, false
+	}
+	return record.Solarradiation / ClearSkyIrradiance(location, record.Date), true
+}
+
+// position returns the geometric elevation and azimuth of the sun,
+// in degrees, above lat and lon at t.
+func position(lat, lon float64, t time.Time) (float64, float64) {
+	declination, eqTime := solar(t)
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	trueSolarTime := t.Sub(midnight).Minutes() + eqTime + 4*lon
+	hourAngle := radians(trueSolarTime/4 - 180)
+	phi := radians(lat)
+
+	cosZenith := math.Sin(phi)*math.Sin(declination) + math.Cos(phi)*math.Cos(declination)*math.Cos(hourAngle)
+	zenith := math.Acos(math.Max(-1, math.Min(1, cosZenith)))
+	azimuth := degrees(math.Atan2(math.Sin(hourAngle),
+		math.Cos(hourAngle)*math.Sin(phi)-math.Tan(declination)*math.Cos(phi))) + 180
+	return 90 - degrees(zenith), math.Mod(azimuth, 360)
+}
+
+// solar returns the declination of the sun, in radians, and the
+// equation of time, in minutes, at t.
+func solar(t time.Time) (float64, float64) {
+	c := julianCentury(t)
+	meanLongitude := radians(math.Mod(280.46646+c*(36000.76983+c*0.0003032), 360))
+	meanAnomaly := radians(357.52911 + c*(35999.05029-0.0001537*c))
+	eccentricity := 0.016708634 - c*(0.000042037+0.0000001267*c)
+	center := math.Sin(meanAnomaly)*(1.914602-c*(0.004817+0.000014*c)) +
+		math.Sin(2*meanAnomaly)*(0.019993-0.000101*c) + math.Sin(3*meanAnomaly)*0.000289
+	omega := radians(125.04 - 1934.136*c)
+	apparentLongitude := degrees(meanLongitude) + center - 0.00569 - 0.00478*math.Sin(omega)
+	meanObliquity := 23 + (26+(21.448-c*(46.815+c*(0.00059-c*0.001813)))/60)/60
+	obliquity := radians(meanObliquity + 0.00256*math.Cos(omega))
+
+	declination := math.Asin(math.Sin(obliquity) * math.Sin(radians(apparentLongitude)))
+	y := math.Pow(math.Tan(obliquity/2), 2)
+	eqTime := 4 * degrees(y*math.Sin(2*meanLongitude)-
+		2*eccentricity*math.Sin(meanAnomaly)+
+		4*eccentricity*y*math.Sin(meanAnomaly)*math.Cos(2*meanLongitude)-
+		0.5*y*y*math.Sin(4*meanLongitude)-
+		1.25*eccentricity*eccentricity*math.Sin(2*meanAnomaly))
+	return declination, eqTime
+}
+
+// sunDistance returns the distance to the sun, in astronomical units,
+// c Julian centuries after J2000.
+func sunDistance(c float64) float64 {
+	meanAnomaly := 357.52911 + c*(35999.05029-0.0001537*c)
+	eccentricity := 0.016708634 - c*(0.000042037+0.0000001267*c)
+	center := math.Sin(radians(meanAnomaly))*(1.914602-c*(0.004817+0.000014*c)) +
+		math.Sin(radians(2*meanAnomaly))*(0.019993-0.000101*c)
+	trueAnomaly := radians(meanAnomaly + center)
+	return 1.000001018 * (1 - eccentricity*eccentricity) / (1 + eccentricity*math.Cos(trueAnomaly))
+}
+
+// julianCentury returns the Julian centuries elapsed since J2000 at t.
+func julianCentury(t time.Time) float64 {
+	julianDay := float64(t.UnixNano())/float64(24*time.Hour) + 2440587.5
+	return (julianDay - 2451545) / 36525
+}
+
+func radians(d float64) float64 { return d * math.Pi / 180 }
+
+func degrees(r float64) float64 { return r * 180 / math.Pi }
+
+func minutes(m float64) time.Duration { return time.Duration(m * float64(time.Minute)) }
@@ -0,0 +1,131 @@
+package astro
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	newYork = ambient.LocationInfo{Coords: ambient.Coords{Lat: 40.7128, Lon: -74.0060}, Elevation: 10}
+	tromso  = ambient.LocationInfo{Coords: ambient.Coords{Lat: 69.6492, Lon: 18.9553}}
+)
+
+func eastern(t *testing.T) *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	require.Nil(t, err)
+	return loc
+}
+
+func Test_SunAt_SunriseSunset(t *testing.T) {
+	loc := eastern(t)
+	sun := SunAt(newYork, time.Date(2023, time.June, 21, 9, 0, 0, 0, loc))
+
+	// Times from the NOAA solar calculator.
+	require.WithinDuration(t, time.Date(2023, time.June, 21, 5, 25, 0, 0, loc), sun.Sunrise, 2*time.Minute)
+	require.WithinDuration(t, time.Date(2023, time.June, 21, 12, 58, 0, 0, loc), sun.Noon, 2*time.Minute)
+	require.WithinDuration(t, time.Date(2023, time.June, 21, 20, 31, 0, 0, loc), sun.Sunset, 2*time.Minute)
+	require.InDelta(t, (15*time.Hour + 6*time.Minute).Minutes(), sun.DayLength.Minutes(), 3)
+	require.Equal(t, loc, sun.Noon.Location())
+	require.True(t, sun.Daylight())
+}
+
+func Test_SunAt_Position(t *testing.T) {
+	loc := eastern(t)
+	noon := SunAt(newYork, time.Date(2023, time.June, 21, 12, 58, 0, 0, loc))
+	// 90° - latitude + declination.
+	require.InDelta(t, 90-40.7128+23.44, noon.Elevation, 0.3)
+	require.InDelta(t, 180, noon.Azimuth, 2)
+
+	morning := SunAt(newYork, time.Date(2023, time.June, 21, 8, 0, 0, 0, loc))
+	require.Less(t, morning.Azimuth, 180.0)
+	require.Greater(t, morning.Elevation, 0.0)
+
+	night := SunAt(newYork, time.Date(2023, time.June, 21, 23, 0, 0, 0, loc))
+	require.Less(t, night.Elevation, 0.0)
+	require.False(t, night.Daylight())
+}
+
+func Test_SunAt_Polar(t *testing.T) {
+	summer := SunAt(tromso, time.Date(2023, time.June, 21, 12, 0, 0, 0, time.UTC))
+	require.Equal(t, 24*time.Hour, summer.DayLength)
+	require.True(t, summer.Sunrise.IsZero())
+	require.True(t, summer.Sunset.IsZero())
+
+	winter := SunAt(tromso, time.Date(2023, time.December, 21, 12, 0, 0, 0, time.UTC))
+	require.Equal(t, time.Duration(0), winter.DayLength)
+	require.True(t, winter.Sunrise.IsZero())
+	require.False(t, winter.Daylight())
+}
+
+func Test_MoonAt(t *testing.T) {
+	tests := []struct {
+		t            time.Time
+		phase        float64
+		illumination float64
+		name         string
+	}{
+		{time.Date(2023, time.August, 16, 9, 38, 0, 0, time.UTC), 0, 0, "New Moon"},
+		{time.Date(2023, time.August, 24, 9, 57, 0, 0, time.UTC), 0.25, 0.5, "First Quarter"},
+		{time.Date(2023, time.August, 31, 1, 35, 0, 0, time.UTC), 0.5, 1, "Full Moon"},
+		{time.Date(2023, time.September, 6, 22, 21, 0, 0, time.UTC), 0.75, 0.5, "Last Quarter"},
+		{time.Date(2023, time.August, 20, 0, 0, 0, 0, time.UTC), 0.12, 0.13, "Waxing Crescent"},
+		{time.Date(1999, time.December, 22, 17, 31, 0, 0, time.UTC), 0.5, 1, "Full Moon"},
+	}
+
+	for _, tt := range tests {
+		moon := MoonAt(tt.t)
+		phase := moon.Phase
+		if tt.phase == 0 && phase > 0.5 {
+			phase--
+		}
+		require.InDelta(t, tt.phase, phase, 0.04, tt.t.String())
+		require.InDelta(t, tt.illumination, moon.Illumination, 0.1, tt.t.String())
+		require.Equal(t, tt.name, moon.Name(), tt.t.String())
+	}
+}
+
+func Test_ClearSkyIrradiance(t *testing.T) {
+	loc := eastern(t)
+	noon := ClearSkyIrradiance(newYork, time.Date(2023, time.June, 21, 12, 58, 0, 0, loc))
+	require.InDelta(t, 943, noon, 10)
+
+	require.Equal(t, 0.0, ClearSkyIrradiance(newYork, time.Date(2023, time.June, 21, 23, 0, 0, 0, loc)))
+
+	high := newYork
+	high.Elevation = 2000
+	require.Greater(t, ClearSkyIrradiance(high, time.Date(2023, time.June, 21, 12, 58, 0, 0, loc)), noon)
+}
+
+func Test_Clearness(t *testing.T) {
+	record := func(date time.Time, fields string) ambient.Record {
+		var record ambient.Record
+		data := fmt.Sprintf(`{"date":%q%s}`, date.Format(time.RFC3339), fields)
+		require.Nil(t, json.Unmarshal([]byte(data), &record))
+		return record
+	}
+	noon := time.Date(2023, time.June, 21, 16, 58, 0, 0, time.UTC)
+
+	clearness, ok := Clearness(newYork, record(noon, `,"solarradiation":850`))
+	require.True(t, ok)
+	require.InDelta(t, 0.9, clearness, 0.02)
+
+	_, ok = Clearness(newYork, record(noon, ``))
+	require.False(t, ok)
+
+	_, ok = Clearness(newYork, record(noon.Add(10*time.Hour), `,"solarradiation":0`))
+	require.False(t, ok)
+}
+
+func Test_At(t *testing.T) {
+	now := time.Date(2023, time.June, 21, 16, 58, 0, 0, time.UTC)
+	sky := At(newYork, now)
+
+	require.Equal(t, SunAt(newYork, now), sky.Sun)
+	require.Equal(t, MoonAt(now), sky.Moon)
+	require.Equal(t, ClearSkyIrradiance(newYork, now), sky.ClearSkyIrradiance)
+}
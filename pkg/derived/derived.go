@@ -0,0 +1,226 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+// Package derived computes meteorological quantities that stations
+// do not report, from the observations in a Record.
+//
+// Functions take their inputs in the units of the API: temperatures
+// in °F, relative humidity in %, wind speeds in mph, pressures in inHg
+// and elevations, like LocationInfo.Elevation, in meters.  Derived
+// temperatures are returned in °F and pressures in inHg; other units
+// are given by each function.
+package derived
+
+import (
+	"math"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+)
+
+// Physical constants.
+const (
+	// dryAirGasConstant is the specific gas constant of dry air, J/(kg·K).
+	dryAirGasConstant = 287.058
+	// vaporGasConstant is the specific gas constant of water vapor, J/(kg·K).
+	vaporGasConstant = 461.495
+	// zeroCelsius is 0 °C in kelvin.
+	zeroCelsius = 273.15
+	// hPaPerInHg converts inHg to hPa.
+	hPaPerInHg = 33.8638866667
+	// cloudBaseLapse is the height, in meters, by which the cloud base
+	// rises for each °C of spread between temperature and dew point.
+	cloudBaseLapse = 125
+)
+
+func fToC(f float64) float64 { return (f - 32) * 5 / 9 }
+
+func cToF(c float64) float64 { return c*9/5 + 32 }
+
+// saturationVaporPressure returns the saturation vapor pressure over
+// water, in hPa, at tempC, using the Magnus formula with the constants
+// recommended by the WMO.
+func saturationVaporPressure(tempC float64) float64 {
+	return 6.112 * math.Exp(17.62*tempC/(243.12+tempC))
+}
+
+// vaporPressure returns the vapor pressure, in hPa.
+func vaporPressure(tempF, humidity float64) float64 {
+	return humidity / 100 * saturationVaporPressure(fToC(tempF))
+}
+
+// HeatIndex returns the heat index following the NWS algorithm: the
+// Steadman approximation below 80 °F, the Rothfusz regression with its
+// low and high humidity adjustments above.
+func HeatIndex(tempF, humidity float64) float64 {
+	simple := 0.5 * (tempF + 61 + (tempF-68)*1.2 + humidity*0.094)
+	if (simple+tempF)/2 < 80 {
+		return simple
+	}
+	t, rh := tempF, humidity
+	hi := -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh -
+		0.00683783*t*t - 0.05481717*rh*rh + 0.00122874*t*t*rh +
+		0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+	switch {
+	case rh < 13 && t >= 80 && t <= 112:
+		hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+	case rh > 85 && t >= 80 && t <= 87:
+		hi += (rh - 85) / 10 * (87 - t) / 5
+	}
+	return hi
+}
+
+// WindChill returns the wind chill following the NWS 2001 formula,
+// which applies at or below 50 °F with winds of at least 3 mph.
+// Otherwise tempF is returned.
+func WindChill(tempF, windMph float64) float64 {
+	if tempF > 50 || windMph < 3 {
+		return tempF
+	}
+	v := math.Pow(windMph, 0.16)
+	return 35.74 + 0.6215*tempF - 35.75*v + 0.4275*tempF*v
+}
+
+// ApparentTemperature returns the apparent temperature, considering
+// humidity and wind but not radiation, from the Steadman formula
+// used by the Australian Bureau of Meteorology.
+func ApparentTemperature(tempF, humidity, windMph float64) float64 {
+	tempC := fToC(tempF)
+	windMs := windMph * 0.44704
+	return cToF(tempC + 0.33*vaporPressure(tempF, humidity) - 0.70*windMs - 4.00)
+}
+
+// DewPoint returns the dew point from the Magnus formula.  It returns
+// NaN when humidity is not above 0, the dew point of dry air being
+// undefined.
+func DewPoint(tempF, humidity float64) float64 {
+	tempC := fToC(tempF)
+	gamma := math.Log(humidity/100) + 17.62*tempC/(243.12+tempC)
+	return cToF(243.12 * gamma / (17.62 - gamma))
+}
+
+// WetBulb returns the wet-bulb temperature at sea level pressure from
+// the Stull (2011) formula, valid for humidities from 5 to 99 % and
+// temperatures from -4 to 122 °F.
+func WetBulb(tempF, humidity float64) float64 {
+	t, rh := fToC(tempF), humidity
+	tw := t*math.Atan(0.151977*math.Sqrt(rh+8.313659)) + math.Atan(t+rh) - math.Atan(rh-1.676331) +
+		0.00391838*math.Pow(rh, 1.5)*math.Atan(0.023101*rh) - 4.686035
+	return cToF(tw)
+}
+
+// Humidex returns the humidex of Environment Canada, a
+// dimensionless index on the scale of degrees Celsius.
+func Humidex(tempF, dewPointF float64) float64 {
+	e := 6.11 * math.Exp(5417.7530*(1/273.16-1/(zeroCelsius+fToC(dewPointF))))
+	return fToC(tempF) + 0.5555*(e-10)
+}
+
+// AbsoluteHumidity returns the mass of water vapor in the air, in g/m³.
+func AbsoluteHumidity(tempF, humidity float64) float64 {
+	return vaporPressure(tempF, humidity) * 100 / (vaporGasConstant * (fToC(tempF) + zeroCelsius)) * 1000
+}
+
+// VaporPressureDeficit returns the difference between the saturation
+// and actual vapor pressures, in kPa, using the FAO-56 formula.
+func VaporPressureDeficit(tempF, humidity float64) float64 {
+	tempC := fToC(tempF)
+	saturation := 0.6108 * math.Exp(17.27*tempC/(tempC+237.3))
+	return saturation * (1 - humidity/100)
+}
+
+// CloudBase returns the height of the base of cumulus clouds above the
+// station, in meters, from the spread between temperature and dew point.
+func CloudBase(tempF, dewPointF float64) float64 {
+	return math.Max(0, (tempF-dewPointF)*5/9*cloudBaseLapse)
+}
+
+// AirDensity returns the density of moist air, in kg/m³, from the
+// station (absolute) pressure.
+func AirDensity(tempF, humidity, stationInHg float64) float64 {
+	tempK := fToC(tempF) + zeroCelsius
+	vapor := vaporPressure(tempF, humidity) * 100
+	dry := stationInHg*hPaPerInHg*100 - vapor
+	return dry/(dryAirGasConstant*tempK) + vapor/(vaporGasConstant*tempK)
+}
+
+// SeaLevelPressure reduces the station pressure to sea level with the
+// barometric formula of the standard atmosphere, using the temperature
+// at the station.
+func SeaLevelPressure(stationInHg, elevation, tempF float64) float64 {
+	h := 0.0065 * elevation
+	return stationInHg * math.Pow(1-h/(fToC(tempF)+h+zeroCelsius), -5.257)
+}
+
+// Quantities are the quantities derived from a Record.
+type Quantities struct {
+	HeatIndex           float64
+	WindChill           float64
+	ApparentTemperature float64
+	DewPoint            float64
+	WetBulb             float64
+	Humidex             float64
+	// HasDewPoint is set when the humidity is above 0, DewPoint,
+	// Humidex and CloudBase being left unset otherwise.
+	HasDewPoint bool
+	// AbsoluteHumidity is in g/m³.
+	AbsoluteHumidity float64
+	// VaporPressureDeficit is in kPa.
+	VaporPressureDeficit float64
+	// CloudBase is in meters above the station.
+	CloudBase float64
+
+	// AirDensity, in kg/m³, and SeaLevelPressure are only
+	// set when HasPressure is, the record holding Baromabsin.
+	AirDensity       float64
+	SeaLevelPressure float64
+	HasPressure      bool
+}
+
+// FromRecord returns the quantities derived from the outdoor
+// observations of record, at a station elevation meters high.  It
+// reports false when record lacks the temperature or humidity.
+// A missing wind speed is taken as calm.
+func FromRecord(record ambient.Record, elevation float64) (Quantities, bool) {
+	if !record.Has("tempf") || !record.Has("humidity") {
+		return Quantities{}, false
+	}
+	t, rh := record.Tempf, float64(record.Humidity)
+	q := Quantities{
+		HeatIndex:            HeatIndex(t, rh),
+		WindChill:            WindChill(t, record.Windspeedmph),
+		ApparentTemperature:  ApparentTemperature(t, rh, record.Windspeedmph),
+		WetBulb:              WetBulb(t, rh),
+		AbsoluteHumidity:     AbsoluteHumidity(t, rh),
+		VaporPressureDeficit: VaporPressureDeficit(t, rh),
+	}
+	if rh > 0 {
+		q.DewPoint = DewPoint(t, rh)
+		q.Humidex = Humidex(t, q.DewPoint)
+		q.CloudBase = CloudBase(t, q.DewPoint)
+		q.HasDewPoint = true
+	}
+	if record.Has("baromabsin") {
+		q.AirDensity = AirDensity(t, rh, record.Baromabsin)
+		q.SeaLevelPressure = SeaLevelPressure(record.Baromabsin, elevation, t)
+		q.HasPressure = true
+	}
+	return q, true
+}
+
+// ChannelDewPoint returns the dew point of sensor channel n of record,
+// as reported or, when the API omits it, computed from the temperature
+// and humidity of the channel.  It reports false when neither is possible.
+func ChannelDewPoint(record ambient.Record, n int) (float64, bool) {
+	channel, ok := record.Channel(n)
+	switch {
+	case !ok:
+		return 0, false
+	case channel.HasDewpoint:
+		return channel.Dewpoint, true
+	case channel.HasTemp && channel.HasHumidity && channel.Humidity > 0:
+		return DewPoint(channel.Temp, float64(channel.Humidity)), true
+	}
+	return 0, false
+}
@@ -0,0 +1,207 @@
+package derived
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_HeatIndex(t *testing.T) {
+	// Expected values from the NWS heat index chart.
+	tests := []struct {
+		tempF, humidity float64
+		expected        float64
+	}{
+		{70, 50, 69},
+		{80, 40, 80},
+		{90, 50, 95},
+		{100, 40, 109},
+		{96, 65, 121},
+		{84, 90, 98},
+		{100, 10, 94},
+	}
+
+	for _, tt := range tests {
+		require.InDelta(t, tt.expected, HeatIndex(tt.tempF, tt.humidity), 0.7, "%v °F %v %%", tt.tempF, tt.humidity)
+	}
+}
+
+func Test_WindChill(t *testing.T) {
+	// Expected values from the NWS wind chill chart.
+	tests := []struct {
+		tempF, windMph float64
+		expected       float64
+	}{
+		{0, 15, -19},
+		{30, 10, 21},
+		{-10, 20, -35},
+		{40, 5, 36},
+		{60, 20, 60},
+		{30, 2, 30},
+	}
+
+	for _, tt := range tests {
+		require.InDelta(t, tt.expected, WindChill(tt.tempF, tt.windMph), 0.5, "%v °F %v mph", tt.tempF, tt.windMph)
+	}
+}
+
+func Test_Humidity(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       func(tempF, humidity float64) float64
+		tempF    float64
+		humidity float64
+		expected float64
+		delta    float64
+	}{
+		{"DewPoint", DewPoint, 68, 50, 48.7, 0.1},
+		{"DewPoint", DewPoint, 86, 70, 75.1, 0.1},
+		{"DewPoint", DewPoint, 32, 100, 32, 0.01},
+		// Stull (2011): 20 °C at 50 % gives 13.7 °C.
+		{"WetBulb", WetBulb, 68, 50, 56.7, 0.1},
+		{"AbsoluteHumidity", AbsoluteHumidity, 68, 50, 8.63, 0.02},
+		{"VaporPressureDeficit", VaporPressureDeficit, 68, 50, 1.169, 0.001},
+	}
+
+	for _, tt := range tests {
+		require.InDelta(t, tt.expected, tt.fn(tt.tempF, tt.humidity), tt.delta, "%s(%v, %v)", tt.name, tt.tempF, tt.humidity)
+	}
+}
+
+func Test_ApparentTemperature(t *testing.T) {
+	// 30 °C, 50 % and 2 m/s give 31.6 °C.
+	require.InDelta(t, 88.9, ApparentTemperature(86, 50, 4.47), 0.1)
+}
+
+func Test_Humidex(t *testing.T) {
+	// Expected values from the Environment Canada humidex table.
+	tests := []struct {
+		tempF, dewPointF float64
+		expected         float64
+	}{
+		{86, 59, 34},
+		{95, 77, 47},
+	}
+
+	for _, tt := range tests {
+		require.InDelta(t, tt.expected, Humidex(tt.tempF, tt.dewPointF), 0.5)
+	}
+}
+
+func Test_CloudBase(t *testing.T) {
+	require.InDelta(t, 1250, CloudBase(68, 50), 0.01)
+	require.Zero(t, CloudBase(50, 60))
+}
+
+func Test_AirDensity(t *testing.T) {
+	tests := []struct {
+		tempF, humidity, stationInHg float64
+		expected                     float64
+	}{
+		// The standard atmosphere at sea level.
+		{59, 0, 29.9213, 1.225},
+		{86, 80, 29.92, 1.150},
+	}
+
+	for _, tt := range tests {
+		require.InDelta(t, tt.expected, AirDensity(tt.tempF, tt.humidity, tt.stationInHg), 0.001)
+	}
+}
+
+func Test_SeaLevelPressure(t *testing.T) {
+	tests := []struct {
+		stationInHg, elevation, tempF float64
+		expected                      float64
+	}{
+		{29.92, 0, 59, 29.92},
+		// 1000 hPa at 300 m and 15 °C give 1036.1 hPa.
+		{29.53, 300, 59, 30.596},
+	}
+
+	for _, tt := range tests {
+		require.InDelta(t, tt.expected, SeaLevelPressure(tt.stationInHg, tt.elevation, tt.tempF), 0.01)
+	}
+}
+
+func Test_FromRecord(t *testing.T) {
+	var record ambient.Record
+	require.Nil(t, json.Unmarshal([]byte(`{"tempf":90,"humidity":50,"windspeedmph":0,"baromabsin":29.53}`), &record))
+
+	q, ok := FromRecord(record, 300)
+
+	require.True(t, ok)
+	require.Equal(t, HeatIndex(90, 50), q.HeatIndex)
+	require.Equal(t, 90.0, q.WindChill)
+	require.Equal(t, DewPoint(90, 50), q.DewPoint)
+	require.Equal(t, Humidex(90, q.DewPoint), q.Humidex)
+	require.True(t, q.HasDewPoint)
+	require.True(t, q.HasPressure)
+	require.Equal(t, SeaLevelPressure(29.53, 300, 90), q.SeaLevelPressure)
+	require.Equal(t, AirDensity(90, 50, 29.53), q.AirDensity)
+}
+
+func Test_FromRecord_DewPoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		humidity int
+		expected bool
+	}{
+		{"humid", 50, true},
+		{"dry", 0, false},
+	}
+
+	for _, tt := range tests {
+		record := ambient.Record{Tempf: 68, Humidity: tt.humidity}
+		require.Nil(t, record.SetPresent("humidity"))
+
+		q, ok := FromRecord(record, 0)
+
+		require.True(t, ok, tt.name)
+		require.Equal(t, tt.expected, q.HasDewPoint, tt.name)
+		require.False(t, math.IsNaN(q.DewPoint), tt.name)
+		require.False(t, math.IsNaN(q.Humidex), tt.name)
+		require.False(t, math.IsNaN(q.CloudBase), tt.name)
+		if !tt.expected {
+			require.Zero(t, q.DewPoint, tt.name)
+			require.Zero(t, q.Humidex, tt.name)
+			require.Zero(t, q.CloudBase, tt.name)
+		}
+	}
+}
+
+func Test_FromRecord_MissingHumidity(t *testing.T) {
+	var record ambient.Record
+	require.Nil(t, json.Unmarshal([]byte(`{"tempf":90}`), &record))
+
+	_, ok := FromRecord(record, 0)
+
+	require.False(t, ok)
+}
+
+func Test_FromRecord_MissingPressure(t *testing.T) {
+	q, ok := FromRecord(ambient.Record{Tempf: 50, Humidity: 40}, 0)
+
+	require.True(t, ok)
+	require.False(t, q.HasPressure)
+	require.Zero(t, q.SeaLevelPressure)
+}
+
+func Test_ChannelDewPoint(t *testing.T) {
+	record := ambient.Record{Temp1f: 68, Humidity1: 50, Temp2f: 70, Dewpoint2: 55, Temp3f: 70}
+
+	dewPoint, ok := ChannelDewPoint(record, 1)
+	require.True(t, ok)
+	require.InDelta(t, 48.7, dewPoint, 0.1)
+
+	dewPoint, ok = ChannelDewPoint(record, 2)
+	require.True(t, ok)
+	require.Equal(t, 55.0, dewPoint)
+
+	_, ok = ChannelDewPoint(record, 3)
+	require.False(t, ok)
+	_, ok = ChannelDewPoint(record, 4)
+	require.False(t, ok)
+}
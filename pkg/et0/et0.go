@@ -0,0 +1,276 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+// Package et0 computes the reference evapotranspiration (ET0) of a
+// station with the FAO-56 Penman-Monteith equation, from its records.
+//
+// ET0 is computed for each hour with the hourly form of the equation
+// (FAO-56 equation 53) from the mean observations of the hour, and
+// summed into days.  Days also hold the rain fallen and the soil
+// moisture, for a water balance.  ET0 and rain are in millimeters.
+//
+// See https://www.fao.org/3/x0490e/x0490e00.htm
+package et0
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+)
+
+// Site describes where a station stands.
+type Site struct {
+	// Latitude and Longitude are in degrees, north and east positive.
+	Latitude, Longitude float64
+	// Elevation is in meters.
+	Elevation float64
+	// WindHeight is the height of the anemometer in meters.
+	// Zero stands for the 2 m of the FAO-56 reference.
+	WindHeight float64
+}
+
+// SiteOf returns the Site of device, from its LocationInfo.
+func SiteOf(device ambient.DeviceRecord) Site {
+	location := device.Info.LocationInfo
+	return Site{
+		Latitude:  location.Coords.Lat,
+		Longitude: location.Coords.Lon,
+		Elevation: location.Elevation,
+	}
+}
+
+// Hour is the ET0 of one hour.
+type Hour struct {
+	// Start is the beginning of the hour.
+	Start time.Time
+	// ET0 is in mm.
+	ET0 float64
+}
+
+// Day is the ET0 and water balance of one day.
+type Day struct {
+	// Date is the local midnight starting the day.
+	Date time.Time
+	// ET0 is the sum of the ET0 of Hours, in mm.
+	ET0 float64
+	// Hours are the hours with enough observations to compute ET0.
+	Hours []Hour
+	// Rain is the rain fallen during the day, in mm,
+	// the highest Dailyrainin reported.
+	Rain float64
+	// Balance is Rain minus ET0 and Cumulative the
+	// sum of Balance since the first day, in mm.
+	Balance, Cumulative float64
+	// SoilMoisture holds the last moisture reported by
+	// each soil sensor during the day, by channel, in %.
+	SoilMoisture map[int]float64
+}
+
+// Constants of FAO-56.
+const (
+	// solarConstant is in MJ/(m²·min).
+	solarConstant = 0.0820
+	// stefanBoltzmann is per hour, in MJ/(K⁴·m²·h).
+	stefanBoltzmann = 2.043e-10
+	albedo          = 0.23
+	// defaultCloudiness is Rs/Rso used at night before
+	// any daytime observation.
+	defaultCloudiness = 0.8
+	// wattsToMJPerHour converts a mean radiation in W/m²
+	// into MJ/m² over an hour.
+	wattsToMJPerHour = 0.0036
+	mmPerInch        = 25.4
+)
+
+// observation holds the mean observations of an hour, in SI units.
+type observation struct {
+	start                          time.Time
+	tempC, humidity, wind, solarMJ float64
+	pressureKPa                    float64
+	hasPressure                    bool
+}
+
+// Hours returns the ET0 of each hour of records with the outdoor
+// temperature and humidity, in chronological order.  A missing wind
+// speed is taken as calm and a missing solar radiation as night.
+// Hours are those of loc, UTC if nil.
+func Hours(site Site, records []ambient.Record, loc *time.Location) []Hour {
+	if loc == nil {
+		loc = time.UTC
+	}
+	observations := hourlyObservations(sorted(records), loc)
+	hours := make([]Hour, 0, len(observations))
+	cloudiness := defaultCloudiness
+	for _, o := range observations {
+		var et0 float64
+		et0, cloudiness = hourlyET0(site, o, cloudiness)
+		hours = append(hours, Hour{Start: o.start, ET0: et0})
+	}
+	return hours
+}
+
+// Days returns the ET0 and water balance of each day of records,
+// in chronological order.  Days are those of loc, UTC if nil, which
+// should be the time zone of the station for Rain to be accurate.
+// Records may come straight from DeviceMac or HistoryRange.
+func Days(site Site, records []ambient.Record, loc *time.Location) []Day {
+	if loc == nil {
+		loc = time.UTC
+	}
+	records = sorted(records)
+	var days []Day
+	index := make(map[int64]int)
+	day := func(t time.Time) *Day {
+		local := t.In(loc)
+		date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		i, ok := index[date.Unix()]
+		if !ok {
+			i = len(days)
+			index[date.Unix()] = i
+			days = append(days, Day{Date: date, SoilMoisture: make(map[int]float64)})
+		}
+		return &days[i]
+	}
+	for _, record := range records {
+		d := day(record.Date)
+		if record.Has("dailyrainin") {
+			d.Rain = math.Max(d.Rain, record.Dailyrainin*mmPerInch)
+		}
+		for _, channel := range record.Channels() {
+			if channel.HasSoilHumidity {
+				d.SoilMoisture[channel.Channel] = channel.SoilHumidity
+			}
+		}
+	}
+	for _, hour := range Hours(site, records, loc) {
+		d := day(hour.Start)
+		d.Hours = append(d.Hours, hour)
+		d.ET0 += hour.ET0
+	}
+	var cumulative float64
+	for i := range days {
+		days[i].Balance = days[i].Rain - days[i].ET0
+		cumulative += days[i].Balance
+		days[i].Cumulative = cumulative
+	}
+	return days
+}
+
+// sorted returns records in chronological order.
+func sorted(records []ambient.Record) []ambient.Record {
+	records = append([]ambient.Record(nil), records...)
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Date.Before(records[j].Date)
+	})
+	return records
+}
+
+// hourlyObservations averages sorted records over each hour of loc.
+func hourlyObservations(records []ambient.Record, loc *time.Location) []observation {
+	var observations []observation
+	var o observation
+	var n, pressures int
+	flush := func() {
+		if n == 0 {
+			return
+		}
+		o.tempC /= float64(n)
+		o.humidity /= float64(n)
+		o.wind /= float64(n)
+		o.solarMJ = o.solarMJ / float64(n) * wattsToMJPerHour
+		if pressures > 0 {
+			o.pressureKPa /= float64(pressures)
+			o.hasPressure = true
+		}
+		observations = append(observations, o)
+	}
+	for _, record := range records {
+		if !record.Has("tempf") || !record.Has("humidity") {
+			continue
+		}
+		local := record.Date.In(loc)
+		start := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), 0, 0, 0, loc)
+		if n == 0 || !start.Equal(o.start) {
+			flush()
+			o, n, pressures = observation{start: start}, 0, 0
+		}
+		n++
+		o.tempC += (record.Tempf - 32) * 5 / 9
+		o.humidity += float64(record.Humidity)
+		o.wind += record.Windspeedmph * 0.44704
+		o.solarMJ += record.Solarradiation
+		if record.Has("baromabsin") {
+			o.pressureKPa += record.Baromabsin * 3.38638866667
+			pressures++
+		}
+	}
+	flush()
+	return observations
+}
+
+// hourlyET0 returns the ET0 of o, in mm, with FAO-56 equation 53, and
+// the cloudiness (Rs/Rso) to use for the next hours.  cloudiness is
+// used at night, when it cannot be measured.
+func hourlyET0(site Site, o observation, cloudiness float64) (float64, float64) {
+	pressure := o.pressureKPa
+	if !o.hasPressure {
+		// FAO-56 equation 7.
+		pressure = 101.3 * math.Pow((293-0.0065*site.Elevation)/293, 5.26)
+	}
+	gamma := 0.665e-3 * pressure
+	saturation := 0.6108 * math.Exp(17.27*o.tempC/(o.tempC+237.3))
+	actual := saturation * o.humidity / 100
+	delta := 4098 * saturation / math.Pow(o.tempC+237.3, 2)
+	u2 := o.wind
+	if site.WindHeight > 0 && site.WindHeight != 2 {
+		// FAO-56 equation 47.
+		u2 = o.wind * 4.87 / math.Log(67.8*site.WindHeight-5.42)
+	}
+
+	ra := extraterrestrialRadiation(site, o.start.Add(30*time.Minute))
+	rso := (0.75 + 2e-5*site.Elevation) * ra
+	if rso > 0.05 && o.solarMJ > 0 {
+		cloudiness = math.Min(o.solarMJ/rso, 1)
+	}
+	tempK := o.tempC + 273.16
+	rnl := stefanBoltzmann * math.Pow(tempK, 4) * (0.34 - 0.14*math.Sqrt(actual)) * (1.35*cloudiness - 0.35)
+	rn := (1-albedo)*o.solarMJ - rnl
+	g := 0.1 * rn
+	if o.solarMJ <= 0 {
+		g = 0.5 * rn
+	}
+
+	et0 := (0.408*delta*(rn-g) + gamma*37/(o.tempC+273)*u2*(saturation-actual)) /
+		(delta + gamma*(1+0.34*u2))
+	return math.Max(et0, 0), cloudiness
+}
+
+// extraterrestrialRadiation returns the radiation reaching the top of the
+// atmosphere above site during the hour centered on mid, in MJ/m², with
+// FAO-56 equations 28 to 33.  The day of the year and the hour angle
+// are those of the local solar time, which may be on another day than
+// UTC.
+func extraterrestrialRadiation(site Site, mid time.Time) float64 {
+	local := mid.UTC().Add(time.Duration(site.Longitude / 15 * float64(time.Hour)))
+	j := float64(local.YearDay())
+	dr := 1 + 0.033*math.Cos(2*math.Pi/365*j)
+	declination := 0.409 * math.Sin(2*math.Pi/365*j-1.39)
+	phi := site.Latitude * math.Pi / 180
+	b := 2 * math.Pi * (j - 81) / 364
+	seasonal := 0.1645*math.Sin(2*b) - 0.1255*math.Cos(b) - 0.025*math.Sin(b)
+	solarTime := math.Mod(float64(local.Hour())+float64(local.Minute())/60+seasonal+24, 24)
+	omega := math.Pi / 12 * (solarTime - 12)
+	sunset := math.Acos(math.Max(-1, math.Min(1, -math.Tan(phi)*math.Tan(declination))))
+	omega1 := math.Max(omega-math.Pi/24, -sunset)
+	omega2 := math.Min(omega+math.Pi/24, sunset)
+	if omega1 >= omega2 {
+		return 0
+	}
+	return 12 * 60 / math.Pi * solarConstant * dr *
+		((omega2-omega1)*math.Sin(phi)*math.Sin(declination) +
+			math.Cos(phi)*math.Cos(declination)*(math.Sin(omega2)-math.Sin(omega1)))
+}
@@ -0,0 +1,131 @@
+package et0
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+	"github.com/stretchr/testify/require"
+)
+
+// ndiaye is the site of FAO-56 example 19, N'Diaye, Senegal.
+var ndiaye = Site{Latitude: 16.217, Longitude: -16.25, Elevation: 8}
+
+// records returns a copy of fields every 5 minutes from start to
+// end, holding the fields present as well as its non-zero ones.
+func records(t *testing.T, start, end time.Time, fields ambient.Record, present ...string) []ambient.Record {
+	require.Nil(t, fields.SetPresent(present...))
+	var result []ambient.Record
+	for date := start; date.Before(end); date = date.Add(5 * time.Minute) {
+		record := fields
+		record.Date = date
+		result = append(result, record)
+	}
+	return result
+}
+
+func Test_Hours_FAO56Example19(t *testing.T) {
+	// 38 °C, 52 %, 3.3 m/s and 2.450 MJ/m² during the hour.
+	afternoon := time.Date(2023, time.October, 1, 14, 0, 0, 0, time.UTC)
+	day := records(t, afternoon, afternoon.Add(time.Hour), ambient.Record{Tempf: 100.4, Humidity: 52, Windspeedmph: 7.382, Solarradiation: 680.56})
+	// 28 °C, 90 %, 1.9 m/s and no radiation.
+	night := time.Date(2023, time.October, 1, 2, 0, 0, 0, time.UTC)
+	dark := records(t, night, night.Add(time.Hour), ambient.Record{Tempf: 82.4, Humidity: 90, Windspeedmph: 4.25}, "solarradiation")
+
+	hours := Hours(ndiaye, append(day, dark...), nil)
+	require.Len(t, hours, 2)
+	require.Equal(t, night, hours[0].Start)
+	require.InDelta(t, 0.0, hours[0].ET0, 0.01)
+	require.Equal(t, afternoon, hours[1].Start)
+	require.InDelta(t, 0.63, hours[1].ET0, 0.01)
+}
+
+func Test_Hours_WindHeight(t *testing.T) {
+	start := time.Date(2023, time.October, 1, 14, 0, 0, 0, time.UTC)
+	day := records(t, start, start.Add(time.Hour), ambient.Record{Tempf: 100.4, Humidity: 52, Windspeedmph: 7.382, Solarradiation: 680.56})
+
+	site := ndiaye
+	site.WindHeight = 10
+	// Wind measured higher is slower at 2 m.
+	require.Less(t, Hours(site, day, nil)[0].ET0, Hours(ndiaye, day, nil)[0].ET0)
+}
+
+func Test_Hours_MissingHumidity(t *testing.T) {
+	start := time.Date(2023, time.October, 1, 14, 0, 0, 0, time.UTC)
+	require.Empty(t, Hours(ndiaye, records(t, start, start.Add(time.Hour), ambient.Record{Tempf: 100.4}), nil))
+}
+
+func Test_extraterrestrialRadiation_AcrossUTCMidnight(t *testing.T) {
+	// Daylight west of Greenwich extends past UTC midnight.
+	west := Site{Latitude: 40, Longitude: -100}
+	for hour := 0; hour < 3; hour++ {
+		mid := time.Date(2023, time.June, 21, hour, 30, 0, 0, time.UTC)
+		require.Greater(t, extraterrestrialRadiation(west, mid), 0.0, mid)
+	}
+	// The morning of Sydney is the UTC evening of the previous day.
+	sydney := Site{Latitude: -33.87, Longitude: 151.21}
+	for hour := 18; hour < 24; hour++ {
+		mid := time.Date(2023, time.December, 20, hour, 30, 0, 0, time.UTC)
+		require.Greater(t, extraterrestrialRadiation(sydney, mid), 0.0, mid)
+	}
+	// Local midnight is dark on both.
+	require.Equal(t, 0.0, extraterrestrialRadiation(west, time.Date(2023, time.June, 21, 6, 30, 0, 0, time.UTC)))
+	require.Equal(t, 0.0, extraterrestrialRadiation(sydney, time.Date(2023, time.December, 21, 13, 30, 0, 0, time.UTC)))
+}
+
+func Test_Days(t *testing.T) {
+	loc := time.FixedZone("UTC-5", -5*3600)
+	first := time.Date(2023, time.July, 1, 0, 0, 0, 0, loc)
+	var history []ambient.Record
+	for hour := 0; hour < 48; hour++ {
+		start := first.Add(time.Duration(hour) * time.Hour)
+		solar := 0.0
+		if h := hour % 24; h >= 6 && h < 20 {
+			solar = 600
+		}
+		rain := 0.0
+		if hour >= 24 && hour%24 >= 12 {
+			rain = 0.5
+		}
+		fields := ambient.Record{
+			Tempf: 86, Humidity: 50, Windspeedmph: 5, Solarradiation: solar,
+			Baromabsin: 29.5, Dailyrainin: rain, Soilhum2: float64(40 - hour/6),
+		}
+		history = append(history, records(t, start, start.Add(time.Hour), fields, "solarradiation", "dailyrainin")...)
+	}
+	// DeviceMac returns the newest records first.
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+
+	days := Days(Site{Latitude: 40, Longitude: -75}, history, loc)
+	require.Len(t, days, 2)
+	for i, day := range days {
+		require.Equal(t, first.AddDate(0, 0, i), day.Date)
+		require.Len(t, day.Hours, 24)
+		var sum float64
+		for _, hour := range day.Hours {
+			sum += hour.ET0
+		}
+		require.InDelta(t, sum, day.ET0, 1e-9)
+		// A hot summer day evaporates several mm.
+		require.InDelta(t, 6, day.ET0, 2)
+	}
+
+	require.Equal(t, 0.0, days[0].Rain)
+	require.InDelta(t, 12.7, days[1].Rain, 1e-9)
+	require.InDelta(t, -days[0].ET0, days[0].Balance, 1e-9)
+	require.InDelta(t, 12.7-days[1].ET0, days[1].Balance, 1e-9)
+	require.InDelta(t, days[0].Balance+days[1].Balance, days[1].Cumulative, 1e-9)
+
+	require.Equal(t, map[int]float64{2: 37}, days[0].SoilMoisture)
+	require.Equal(t, map[int]float64{2: 33}, days[1].SoilMoisture)
+}
+
+func Test_SiteOf(t *testing.T) {
+	var device ambient.DeviceRecord
+	device.Info.LocationInfo.Coords = ambient.Coords{Lat: 45.5, Lon: -122.6}
+	device.Info.LocationInfo.Elevation = 50
+
+	require.Equal(t, Site{Latitude: 45.5, Longitude: -122.6, Elevation: 50}, SiteOf(device))
+}
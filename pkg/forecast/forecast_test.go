@@ -0,0 +1,131 @@
+package forecast
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewTendency(t *testing.T) {
+	tests := []struct {
+		start, middle, end float64
+		expected           Characteristic
+		change             float64
+		description        string
+	}{
+		{1010, 1012, 1011, IncreasingThenDecreasing, 1, "rising slowly"},
+		{1010, 1012, 1010, IncreasingThenDecreasing, 0, "steady"},
+		{1010, 1012, 1012, IncreasingThenSteady, 2, "rising"},
+		{1010, 1012, 1012.5, IncreasingThenSteady, 2.5, "rising"},
+		{1010, 1011, 1012, Increasing, 2, "rising"},
+		{1010, 1009, 1012, DecreasingThenIncreasing, 2, "rising"},
+		{1010, 1010, 1012, DecreasingThenIncreasing, 2, "rising"},
+		{1010, 1010.5, 1012.5, DecreasingThenIncreasing, 2.5, "rising"},
+		{1010, 1010.02, 1010.04, Steady, 0, "steady"},
+		{1010, 1008, 1010, DecreasingThenIncreasingLower, 0, "steady"},
+		{1010, 1008, 1009, DecreasingThenIncreasingLower, -1, "falling slowly"},
+		{1010, 1008, 1008, DecreasingThenSteady, -2, "falling"},
+		{1010, 1006, 1005.5, DecreasingThenSteady, -4.5, "falling quickly"},
+		{1010, 1007, 1004, Decreasing, -6, "falling quickly"},
+		{1010, 1006, 1002, Decreasing, -8, "falling very rapidly"},
+		{1010, 1011, 1008, SteadyThenDecreasing, -2, "falling"},
+		{1010, 1010, 1008, SteadyThenDecreasing, -2, "falling"},
+		{1010, 1009.5, 1007.5, SteadyThenDecreasing, -2.5, "falling"},
+	}
+
+	for _, tt := range tests {
+		tendency := NewTendency(tt.start, tt.middle, tt.end)
+		name := fmt.Sprint(tt.start, tt.middle, tt.end)
+		require.Equal(t, tt.expected, tendency.Characteristic, name)
+		require.InDelta(t, tt.change, tendency.Change, 1e-9, name)
+		require.Equal(t, tt.description, tendency.String(), name)
+	}
+}
+
+func Test_Characteristic_String(t *testing.T) {
+	require.Equal(t, "increasing, then decreasing", IncreasingThenDecreasing.String())
+	require.Equal(t, "steady or increasing, then decreasing", SteadyThenDecreasing.String())
+	require.Equal(t, "unknown", Characteristic(9).String())
+}
+
+// history returns records every 10 minutes over the three hours
+// before endDate, newest first like DeviceMac, with the pressure changing
+// linearly from start to end inHg.  Each record also holds fields,
+// with the fields present marked even when zero.
+func history(t *testing.T, endDate time.Time, start, end float64, fields ambient.Record, present ...string) []ambient.Record {
+	var records []ambient.Record
+	for i := 0; i <= 18; i++ {
+		record := fields
+		record.Date = endDate.Add(-time.Duration(i) * 10 * time.Minute)
+		record.Baromrelin = end - (end-start)*float64(i)/18
+		require.Nil(t, record.SetPresent(present...))
+		records = append(records, record)
+	}
+	return records
+}
+
+func Test_PressureTendency(t *testing.T) {
+	now := time.Date(2023, time.January, 15, 12, 0, 0, 0, time.UTC)
+	tendency, ok := PressureTendency(history(t, now, 29.90, 29.75, ambient.Record{}))
+	require.True(t, ok)
+	require.Equal(t, Decreasing, tendency.Characteristic)
+	require.InDelta(t, -5.1, tendency.Change, 1e-9)
+	require.Equal(t, RateQuickly, tendency.Rate)
+}
+
+func Test_PressureTendency_ShortHistory(t *testing.T) {
+	now := time.Date(2023, time.January, 15, 12, 0, 0, 0, time.UTC)
+	_, ok := PressureTendency(history(t, now, 29.90, 29.75, ambient.Record{})[:12])
+	require.False(t, ok)
+
+	_, ok = PressureTendency([]ambient.Record{{Date: now}})
+	require.False(t, ok)
+}
+
+func Test_Zambretti(t *testing.T) {
+	july := time.Date(2023, time.July, 1, 12, 0, 0, 0, time.UTC)
+	january := time.Date(2023, time.January, 1, 12, 0, 0, 0, time.UTC)
+	rising := NewTendency(1010, 1011, 1012)
+	falling := NewTendency(1012, 1011, 1010)
+	steady := NewTendency(1010, 1010, 1010)
+
+	tests := []struct {
+		name       string
+		conditions Conditions
+		letter     byte
+		text       string
+	}{
+		{"rising in summer", Conditions{Pressure: 1030, Tendency: rising, Date: july, Latitude: 45}, 'A', "Settled fine"},
+		{"falling in winter", Conditions{Pressure: 1000, Tendency: falling, Date: january, Latitude: 45}, 'X', "Rain, very unsettled"},
+		{"steady", Conditions{Pressure: 1015, Tendency: steady, Date: july, Latitude: 45}, 'B', "Fine weather"},
+		{"southerly wind", Conditions{Pressure: 1015, Tendency: steady, WindDir: 180, HasWind: true, Date: july, Latitude: 45}, 'N', "Showery, bright intervals"},
+		{"northerly wind south", Conditions{Pressure: 1015, Tendency: steady, WindDir: 0, HasWind: true, Date: july, Latitude: -35}, 'N', "Showery, bright intervals"},
+		{"rising in southern winter", Conditions{Pressure: 1005, Tendency: rising, Date: july, Latitude: -35}, 'F', "Fairly fine, improving"},
+		{"rising in northern summer", Conditions{Pressure: 1005, Tendency: rising, Date: july, Latitude: 45}, 'C', "Becoming fine"},
+		{"very low", Conditions{Pressure: 900, Tendency: steady, Date: july}, 'Z', "Stormy, much rain"},
+		{"very high", Conditions{Pressure: 1100, Tendency: falling, Date: july}, 'A', "Settled fine"},
+	}
+
+	for _, tt := range tests {
+		forecast := Zambretti(tt.conditions)
+		require.Equal(t, string(tt.letter), string(forecast.Letter), tt.name)
+		require.Equal(t, tt.text, forecast.String(), tt.name)
+		require.Equal(t, tt.conditions.Tendency, forecast.Tendency, tt.name)
+	}
+}
+
+func Test_FromRecords(t *testing.T) {
+	now := time.Date(2023, time.January, 15, 12, 0, 0, 0, time.UTC)
+	location := ambient.LocationInfo{Coords: ambient.Coords{Lat: 40}}
+
+	forecast, ok := FromRecords(location, history(t, now, 29.90, 29.75, ambient.Record{Winddir_avg10m: 180}))
+	require.True(t, ok)
+	require.Equal(t, "X", string(forecast.Letter))
+	require.Equal(t, Decreasing, forecast.Tendency.Characteristic)
+
+	_, ok = FromRecords(location, nil)
+	require.False(t, ok)
+}
@@ -0,0 +1,250 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+// Package forecast classifies the pressure tendency of a station
+// following the WMO codes and makes a short-range local forecast with
+// the Zambretti algorithm, from a few hours of records.
+//
+// Pressures are in hPa, the unit of both the WMO codes and Zambretti;
+// InHgToHPa converts the Baromrelin of a Record.
+package forecast
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+)
+
+// InHgToHPa converts inHg to hPa.
+const InHgToHPa = 33.8638866667
+
+// TendencyPeriod is the period over which the tendency is measured.
+const TendencyPeriod = 3 * time.Hour
+
+// tendencyTolerance is how far from the start and middle of
+// the period the records used for the tendency may be.
+const tendencyTolerance = 20 * time.Minute
+
+// Characteristic is the characteristic of the pressure tendency over
+// the last three hours, code a of WMO code table 0200.
+type Characteristic int
+
+const (
+	// IncreasingThenDecreasing is code 0: increasing, then decreasing;
+	// pressure the same or higher than three hours ago.
+	IncreasingThenDecreasing Characteristic = iota
+	// IncreasingThenSteady is code 1: increasing, then steady; or
+	// increasing, then increasing more slowly.
+	IncreasingThenSteady
+	// Increasing is code 2: increasing, steadily or unsteadily.
+	Increasing
+	// DecreasingThenIncreasing is code 3: decreasing or steady, then
+	// increasing; or increasing, then increasing more rapidly.
+	DecreasingThenIncreasing
+	// Steady is code 4: steady; pressure the same as three hours ago.
+	Steady
+	// DecreasingThenIncreasingLower is code 5: decreasing, then
+	// increasing; pressure the same or lower than three hours ago.
+	DecreasingThenIncreasingLower
+	// DecreasingThenSteady is code 6: decreasing, then steady; or
+	// decreasing, then decreasing more slowly.
+	DecreasingThenSteady
+	// Decreasing is code 7: decreasing, steadily or unsteadily.
+	Decreasing
+	// SteadyThenDecreasing is code 8: steady or increasing, then
+	// decreasing; or decreasing, then decreasing more rapidly.
+	SteadyThenDecreasing
+)
+
+var characteristics = [...]string{
+	"increasing, then decreasing",
+	"increasing, then steady",
+	"increasing",
+	"decreasing or steady, then increasing",
+	"steady",
+	"decreasing, then increasing",
+	"decreasing, then steady",
+	"decreasing",
+	"steady or increasing, then decreasing",
+}
+
+func (c Characteristic) String() string {
+	if c < 0 || int(c) >= len(characteristics) {
+		return "unknown"
+	}
+	return characteristics[c]
+}
+
+// Rate is how fast the pressure changes, in the terms of the
+// shipping forecast.
+type Rate int
+
+const (
+	// RateSteady is a change of less than 0.1 hPa in three hours.
+	RateSteady Rate = iota
+	// RateSlowly is a change of 0.1 to 1.5 hPa.
+	RateSlowly
+	// RateModerate is a change of 1.6 to 3.5 hPa.
+	RateModerate
+	// RateQuickly is a change of 3.6 to 6.0 hPa.
+	RateQuickly
+	// RateVeryRapidly is a change of more than 6.0 hPa.
+	RateVeryRapidly
+)
+
+// rateOf returns the rate of change, a change in hPa over three hours.
+func rateOf(change float64) Rate {
+	change = math.Abs(change)
+	switch {
+	case change < 0.1:
+		return RateSteady
+	case change < 1.6:
+		return RateSlowly
+	case change < 3.6:
+		return RateModerate
+	case change <= 6:
+		return RateQuickly
+	}
+	return RateVeryRapidly
+}
+
+// Tendency is the tendency of the pressure over the last three hours.
+type Tendency struct {
+	// Change is the change of pressure over the three hours, in hPa,
+	// rounded to a tenth as WMO code ppp.
+	Change         float64
+	Characteristic Characteristic
+	Rate           Rate
+}
+
+// String describes t, e.g. "rising slowly" or "falling very rapidly".
+func (t Tendency) String() string {
+	direction := "rising"
+	if t.Change < 0 {
+		direction = "falling"
+	}
+	switch t.Rate {
+	case RateSteady:
+		return "steady"
+	case RateSlowly:
+		return direction + " slowly"
+	case RateQuickly:
+		return direction + " quickly"
+	case RateVeryRapidly:
+		return direction + " very rapidly"
+	}
+	return direction
+}
+
+// NewTendency returns the tendency of pressures measured, in hPa, three
+// hours ago, an hour and a half ago and now.
+func NewTendency(start, middle, end float64) Tendency {
+	// Changes are compared in tenths of hPa, under which the pressure
+	// is steady.  The second half of the period changing less than half
+	// or more than twice as much as the first is slower or more rapid.
+	tenths := func(change float64) int { return int(math.Round(change * 10)) }
+	net, first, second := tenths(end-start), tenths(middle-start), tenths(end-middle)
+	up := func(change int) bool { return change > 0 }
+	down := func(change int) bool { return change < 0 }
+
+	var c Characteristic
+	switch {
+	case !up(net) && !down(net):
+		switch {
+		case up(first) && down(second):
+			c = IncreasingThenDecreasing
+		case down(first) && up(second):
+			c = DecreasingThenIncreasingLower
+		default:
+			c = Steady
+		}
+	case up(net):
+		switch {
+		case !up(first):
+			c = DecreasingThenIncreasing
+		case down(second):
+			c = IncreasingThenDecreasing
+		case !up(second) || 2*second < first:
+			c = IncreasingThenSteady
+		case second > 2*first:
+			c = DecreasingThenIncreasing
+		default:
+			c = Increasing
+		}
+	default:
+		switch {
+		case !down(first):
+			c = SteadyThenDecreasing
+		case up(second):
+			c = DecreasingThenIncreasingLower
+		case !down(second) || 2*second > first:
+			c = DecreasingThenSteady
+		case second < 2*first:
+			c = SteadyThenDecreasing
+		default:
+			c = Decreasing
+		}
+	}
+	change := float64(net) / 10
+	return Tendency{Change: change, Characteristic: c, Rate: rateOf(change)}
+}
+
+// PressureTendency returns the tendency of the Baromrelin of records,
+// over the three hours before the latest record holding it.  It reports
+// false when records do not hold the pressure about three hours and an
+// hour and a half before.  Records may be in any order, such as the
+// newest first order of DeviceMac.
+func PressureTendency(records []ambient.Record) (Tendency, bool) {
+	var history []ambient.Record
+	for _, record := range records {
+		if record.Has("baromrelin") {
+			history = append(history, record)
+		}
+	}
+	if len(history) == 0 {
+		return Tendency{}, false
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Date.Before(history[j].Date)
+	})
+	end := history[len(history)-1]
+	start, ok := closest(history, end.Date.Add(-TendencyPeriod))
+	if !ok {
+		return Tendency{}, false
+	}
+	middle, ok := closest(history, end.Date.Add(-TendencyPeriod/2))
+	if !ok {
+		return Tendency{}, false
+	}
+	return NewTendency(start.Baromrelin*InHgToHPa, middle.Baromrelin*InHgToHPa, end.Baromrelin*InHgToHPa), true
+}
+
+// closest returns the record of sorted history closest to t, and
+// whether it is within tendencyTolerance of t.
+func closest(history []ambient.Record, t time.Time) (ambient.Record, bool) {
+	i := sort.Search(len(history), func(i int) bool { return !history[i].Date.Before(t) })
+	best := -1
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(history) {
+			continue
+		}
+		if best < 0 || absDuration(history[j].Date.Sub(t)) < absDuration(history[best].Date.Sub(t)) {
+			best = j
+		}
+	}
+	if best < 0 || absDuration(history[best].Date.Sub(t)) > tendencyTolerance {
+		return ambient.Record{}, false
+	}
+	return history[best], true
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
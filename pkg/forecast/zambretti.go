@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+package forecast
+
+import (
+	"math"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+)
+
+// The range of sea-level pressures, in hPa, covered by Zambretti.
+const (
+	zambrettiBottom = 950
+	zambrettiTop    = 1050
+	zambrettiRange  = zambrettiTop - zambrettiBottom
+)
+
+// zambrettiForecasts are the 26 forecasts of Zambretti, A to Z.
+var zambrettiForecasts = [...]string{
+	"Settled fine",
+	"Fine weather",
+	"Becoming fine",
+	"Fine, becoming less settled",
+	"Fine, possible showers",
+	"Fairly fine, improving",
+	"Fairly fine, possible showers early",
+	"Fairly fine, showery later",
+	"Showery early, improving",
+	"Changeable, mending",
+	"Fairly fine, showers likely",
+	"Rather unsettled, clearing later",
+	"Unsettled, probably improving",
+	"Showery, bright intervals",
+	"Showery, becoming less settled",
+	"Changeable, some rain",
+	"Unsettled, short fine intervals",
+	"Unsettled, rain later",
+	"Unsettled, some rain",
+	"Mostly very unsettled",
+	"Occasional rain, worsening",
+	"Rain at times, very unsettled",
+	"Rain at frequent intervals",
+	"Rain, very unsettled",
+	"Stormy, may improve",
+	"Stormy, much rain",
+}
+
+// The forecast, as an index into zambrettiForecasts, for each of 22
+// bands of pressure from zambrettiBottom to zambrettiTop, when the
+// pressure is rising, steady or falling.
+var (
+	risingForecasts  = [22]int{25, 25, 25, 24, 24, 19, 16, 12, 11, 9, 8, 6, 5, 2, 1, 1, 0, 0, 0, 0, 0, 0}
+	steadyForecasts  = [22]int{25, 25, 25, 25, 25, 25, 23, 23, 22, 18, 15, 13, 10, 4, 1, 1, 0, 0, 0, 0, 0, 0}
+	fallingForecasts = [22]int{25, 25, 25, 25, 25, 25, 25, 25, 23, 23, 21, 20, 17, 14, 7, 3, 1, 1, 1, 0, 0, 0}
+)
+
+// windAdjustments are the corrections of the pressure, in percent of
+// zambrettiRange, for the wind blowing from each of the 16 points of
+// the compass, from north, in the northern hemisphere.
+var windAdjustments = [16]float64{6, 5, 5, 2, -0.5, -2, -5, -8.5, -12, -10, -6, -4.5, -3, -0.5, 1.5, 3}
+
+// seasonAdjustment is the correction of the pressure, in percent of
+// zambrettiRange, when it rises in summer or falls in winter.
+const seasonAdjustment = 7
+
+// Conditions are the inputs of a Zambretti forecast.
+type Conditions struct {
+	// Pressure is the sea-level pressure, in hPa.
+	Pressure float64
+	Tendency Tendency
+	// WindDir is the direction the wind blows from, in degrees,
+	// used when HasWind is set.
+	WindDir float64
+	HasWind bool
+	// Date gives the season, with Latitude the hemisphere.
+	Date     time.Time
+	Latitude float64
+}
+
+// Forecast is a Zambretti forecast.
+type Forecast struct {
+	// Letter is the letter of the forecast in Zambretti, 'A' to 'Z',
+	// from the most settled to the most stormy.
+	Letter byte
+	// Text is the forecast, e.g. "Fairly fine, showers likely".
+	Text     string
+	Tendency Tendency
+}
+
+func (f Forecast) String() string {
+	return f.Text
+}
+
+// Zambretti returns the forecast for the next hours with the algorithm of
+// the Negretti & Zambra forecaster.  The pressure is considered rising or
+// falling when it changes by at least 1.6 hPa in three hours.
+func Zambretti(conditions Conditions) Forecast {
+	pressure := conditions.Pressure
+	southern := conditions.Latitude < 0
+	if conditions.HasWind {
+		direction := conditions.WindDir
+		if southern {
+			direction += 180
+		}
+		point := int(math.Mod(math.Round(direction/22.5), 16)+16) % 16
+		pressure += windAdjustments[point] / 100 * zambrettiRange
+	}
+
+	month := conditions.Date.Month()
+	summer := month >= time.April && month <= time.September
+	if southern {
+		summer = !summer
+	}
+
+	tendency := conditions.Tendency
+	options := steadyForecasts
+	switch {
+	case tendency.Rate >= RateModerate && tendency.Change > 0:
+		options = risingForecasts
+		if summer {
+			pressure += seasonAdjustment / 100.0 * zambrettiRange
+		}
+	case tendency.Rate >= RateModerate && tendency.Change < 0:
+		options = fallingForecasts
+		if !summer {
+			pressure -= seasonAdjustment / 100.0 * zambrettiRange
+		}
+	}
+
+	band := int(math.Floor((pressure - zambrettiBottom) / (zambrettiRange / 22.0)))
+	if band < 0 {
+		band = 0
+	}
+	if band > 21 {
+		band = 21
+	}
+	index := options[band]
+	return Forecast{Letter: byte('A' + index), Text: zambrettiForecasts[index], Tendency: tendency}
+}
+
+// FromRecords returns the Zambretti forecast for a station at location,
+// from the Baromrelin of the last three hours of records and the
+// Winddir_avg10m of the latest record.  It reports false when the
+// pressure tendency cannot be computed.
+func FromRecords(location ambient.LocationInfo, records []ambient.Record) (Forecast, bool) {
+	tendency, ok := PressureTendency(records)
+	if !ok {
+		return Forecast{}, false
+	}
+	latest := latestWithPressure(records)
+	conditions := Conditions{
+		Pressure: latest.Baromrelin * InHgToHPa,
+		Tendency: tendency,
+		Date:     latest.Date,
+		Latitude: location.Coords.Lat,
+	}
+	if latest.Has("winddir_avg10m") {
+		conditions.WindDir, conditions.HasWind = float64(latest.Winddir_avg10m), true
+	}
+	return Zambretti(conditions), true
+}
+
+// latestWithPressure returns the latest record holding the Baromrelin.
+func latestWithPressure(records []ambient.Record) ambient.Record {
+	var latest ambient.Record
+	for _, record := range records {
+		if record.Has("baromrelin") && (latest.Date.IsZero() || record.Date.After(latest.Date)) {
+			latest = record
+		}
+	}
+	return latest
+}
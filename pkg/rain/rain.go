@@ -0,0 +1,220 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+// Package rain derives the rain fallen between consecutive records of a
+// station from its rain counters, and the storms it makes up.
+//
+// A Record holds six cumulative rain counters, Eventrainin, Dailyrainin,
+// Weeklyrainin, Monthlyrainin, Yearlyrainin and Totalrainin, which the
+// console resets at its own boundaries and which may jump when it
+// reboots.  Between two records, a counter going down has been reset.
+// The rain fallen is the change agreed upon by the counters that were
+// not reset, so that a single counter rolling over or jumping does not
+// count rain twice.  When fewer than three counters changed, it is the
+// largest change that is not a jump.  Rain is in inches and rates in
+// inches per hour.
+package rain
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+)
+
+// counters are the API names of the cumulative rain counters.
+var counters = []string{"eventrainin", "dailyrainin", "weeklyrainin", "monthlyrainin", "yearlyrainin", "totalrainin"}
+
+// MaxRate is the rain rate, in inches per hour, above which an increment
+// is taken for a counter jump rather than rain.  It is above the highest
+// hourly rainfall ever recorded.
+const MaxRate = 15
+
+// DefaultStormGap is the dry spell that separates two storms.
+const DefaultStormGap = 6 * time.Hour
+
+// resolution is the resolution of the counters, in inches,
+// to which increments are rounded.
+const resolution = 0.001
+
+// Increment is the rain fallen between two consecutive records.
+type Increment struct {
+	// Start and End are the dates of the two records.
+	Start, End time.Time
+	// Rain is the rain fallen, in inches.
+	Rain float64
+	// Rate is the mean rain rate over the increment, in inches per hour.
+	Rate float64
+	// Reset is set when a counter was reset during the increment.
+	Reset bool
+	// Jump is set when the counters changed by more than
+	// MaxRate allows, in which case Rain is 0.
+	Jump bool
+	// LastRain is the LastRain of the record at End.
+	LastRain time.Time
+}
+
+// Increments returns the rain fallen between each pair of consecutive
+// records holding a rain counter.  records must be in chronological
+// order, without duplicates, as returned by Sorted.
+func Increments(records []ambient.Record) []Increment {
+	var withCounters []ambient.Record
+	for _, record := range records {
+		if hasCounter(record) {
+			withCounters = append(withCounters, record)
+		}
+	}
+	records = withCounters
+	var increments []Increment
+	for i := 1; i < len(records); i++ {
+		previous, current := records[i-1], records[i]
+		increment := Increment{Start: previous.Date, End: current.Date, LastRain: current.LastRain}
+		var changes, resets []float64
+		for _, counter := range counters {
+			before, ok := previous.Number(counter)
+			if !ok {
+				continue
+			}
+			after, ok := current.Number(counter)
+			if !ok {
+				continue
+			}
+			if after < before-resolution/2 {
+				resets = append(resets, after)
+				continue
+			}
+			changes = append(changes, math.Max(after-before, 0))
+		}
+		hours := current.Date.Sub(previous.Date).Hours()
+		switch {
+		case len(changes) >= 3:
+			increment.Rain = lowerMedian(changes)
+		case len(changes) > 0:
+			// Too few counters to outvote a jump: trust the
+			// largest change that is not one.
+			increment.Rain = largest(changes, hours)
+		case len(resets) > 0:
+			// Every counter was reset: only the rain since
+			// the reset is known.
+			increment.Rain = lowerMedian(resets)
+		default:
+			continue
+		}
+		increment.Reset = len(resets) > 0
+		increment.Rain = math.Round(increment.Rain/resolution) * resolution
+		if hours > 0 {
+			increment.Rate = increment.Rain / hours
+			if increment.Rate > MaxRate {
+				increment.Rain, increment.Rate, increment.Jump = 0, 0, true
+			}
+		}
+		increments = append(increments, increment)
+	}
+	return increments
+}
+
+// Total returns the rain fallen over increments, in inches.
+func Total(increments []Increment) float64 {
+	var total float64
+	for _, increment := range increments {
+		total += increment.Rain
+	}
+	return math.Round(total/resolution) * resolution
+}
+
+// Storm is a rain event.
+type Storm struct {
+	// Start is the date of the record before the first rain and End
+	// that of the last rain, the LastRain reported when available.
+	Start, End time.Time
+	// Rain is the rain fallen during the storm, in inches.
+	Rain float64
+	// PeakRate is the highest rate of the increments of the
+	// storm, in inches per hour.
+	PeakRate float64
+}
+
+// Duration returns the duration of the storm.
+func (s Storm) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Storms groups the increments with rain into storms, separated by dry
+// spells of at least gap, DefaultStormGap if gap is 0.  increments
+// must be in chronological order, as returned by Increments.
+func Storms(increments []Increment, gap time.Duration) []Storm {
+	if gap == 0 {
+		gap = DefaultStormGap
+	}
+	var storms []Storm
+	for _, increment := range increments {
+		if increment.Rain <= 0 {
+			continue
+		}
+		end := increment.End
+		if !increment.LastRain.IsZero() && increment.LastRain.After(increment.Start) && !increment.LastRain.After(increment.End) {
+			end = increment.LastRain
+		}
+		if len(storms) == 0 || increment.Start.Sub(storms[len(storms)-1].End) >= gap {
+			storms = append(storms, Storm{Start: increment.Start})
+		}
+		storm := &storms[len(storms)-1]
+		storm.End = end
+		storm.Rain = math.Round((storm.Rain+increment.Rain)/resolution) * resolution
+		storm.PeakRate = math.Max(storm.PeakRate, increment.Rate)
+	}
+	return storms
+}
+
+// hasCounter reports whether record holds a rain counter.
+func hasCounter(record ambient.Record) bool {
+	for _, counter := range counters {
+		if record.Has(counter) {
+			return true
+		}
+	}
+	return false
+}
+
+// lowerMedian returns the median of values, the lower of the two
+// middle values for an even count so as not to overcount rain.
+func lowerMedian(values []float64) float64 {
+	sort.Float64s(values)
+	return values[(len(values)-1)/2]
+}
+
+// largest returns the largest of values not above MaxRate over hours,
+// or the largest of all values if they all are.
+func largest(values []float64, hours float64) float64 {
+	sort.Float64s(values)
+	for i := len(values) - 1; i >= 0; i-- {
+		if hours <= 0 || values[i]/hours <= MaxRate {
+			return values[i]
+		}
+	}
+	return values[len(values)-1]
+}
+
+// Sorted returns the records with a date in chronological order,
+// without duplicates, such as from the newest first order of DeviceMac.
+func Sorted(records []ambient.Record) []ambient.Record {
+	result := make([]ambient.Record, 0, len(records))
+	for _, record := range records {
+		if !record.Date.IsZero() {
+			result = append(result, record)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Date.Before(result[j].Date)
+	})
+	unique := result[:0]
+	for _, record := range result {
+		if len(unique) == 0 || !record.Date.Equal(unique[len(unique)-1].Date) {
+			unique = append(unique, record)
+		}
+	}
+	return unique
+}
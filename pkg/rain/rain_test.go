@@ -0,0 +1,151 @@
+package rain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2023, time.May, 1, 23, 0, 0, 0, time.UTC)
+
+// record returns fields dated minutes after start, holding the
+// fields present as well as its non-zero ones.
+func record(t *testing.T, minutes int, fields ambient.Record, present ...string) ambient.Record {
+	fields.Date = start.Add(time.Duration(minutes) * time.Minute)
+	require.Nil(t, fields.SetPresent(present...))
+	return fields
+}
+
+// rainFields returns the rain counters of a record, the weekly to
+// total ones being offsets of total.
+func rainFields(daily, event, total float64) ambient.Record {
+	return ambient.Record{
+		Dailyrainin: daily, Eventrainin: event, Weeklyrainin: total + 1,
+		Monthlyrainin: total + 2, Yearlyrainin: total + 3, Totalrainin: total + 10,
+	}
+}
+
+func Test_Increments(t *testing.T) {
+	records := []ambient.Record{
+		// Newest first, like DeviceMac.
+		record(t, 30, rainFields(0.05, 0.35, 0.35)),
+		record(t, 15, ambient.Record{Tempf: 60}),
+		record(t, 0, rainFields(0.30, 0.30, 0.30)),
+	}
+
+	increments := Increments(Sorted(records))
+	require.Len(t, increments, 1)
+	increment := increments[0]
+	require.Equal(t, start, increment.Start)
+	require.Equal(t, start.Add(30*time.Minute), increment.End)
+	// Dailyrainin was reset at midnight: 0.05 fell, not 0.30 more.
+	require.InDelta(t, 0.05, increment.Rain, 1e-9)
+	require.InDelta(t, 0.1, increment.Rate, 1e-9)
+	require.True(t, increment.Reset)
+	require.False(t, increment.Jump)
+}
+
+func Test_Increments_CounterJump(t *testing.T) {
+	// Totalrainin jumps after a reboot of the console.
+	records := []ambient.Record{
+		record(t, 0, ambient.Record{Dailyrainin: 0.1, Totalrainin: 12.0, Yearlyrainin: 5.0}),
+		record(t, 5, ambient.Record{Dailyrainin: 0.1, Totalrainin: 40.0, Yearlyrainin: 5.0}),
+	}
+
+	increments := Increments(records)
+	require.Len(t, increments, 1)
+	require.Equal(t, 0.0, increments[0].Rain)
+	require.False(t, increments[0].Reset)
+}
+
+func Test_Increments_TwoCounters(t *testing.T) {
+	// Only Totalrainin saw the rain.
+	records := []ambient.Record{
+		record(t, 0, ambient.Record{Dailyrainin: 0.2, Totalrainin: 10.0}),
+		record(t, 60, ambient.Record{Dailyrainin: 0.2, Totalrainin: 10.1}),
+		// A jump of Totalrainin is not rain.
+		record(t, 65, ambient.Record{Dailyrainin: 0.3, Totalrainin: 40.0}),
+	}
+
+	increments := Increments(records)
+	require.Len(t, increments, 2)
+	require.InDelta(t, 0.1, increments[0].Rain, 1e-9)
+	require.InDelta(t, 0.1, increments[1].Rain, 1e-9)
+	require.False(t, increments[1].Jump)
+}
+
+func Test_Sorted(t *testing.T) {
+	records := []ambient.Record{
+		record(t, 10, ambient.Record{}),
+		record(t, 0, ambient.Record{}),
+		record(t, 10, ambient.Record{}),
+		{},
+	}
+
+	result := Sorted(records)
+	require.Len(t, result, 2)
+	require.Equal(t, start, result[0].Date)
+	require.Equal(t, start.Add(10*time.Minute), result[1].Date)
+}
+
+func Test_Increments_EveryCounterReset(t *testing.T) {
+	records := []ambient.Record{
+		record(t, 0, ambient.Record{Dailyrainin: 0.5, Eventrainin: 0.5, Totalrainin: 20.5}),
+		record(t, 5, ambient.Record{Dailyrainin: 0.02, Eventrainin: 0.02, Totalrainin: 0.02}),
+	}
+
+	increments := Increments(records)
+	require.Len(t, increments, 1)
+	require.InDelta(t, 0.02, increments[0].Rain, 1e-9)
+	require.True(t, increments[0].Reset)
+}
+
+func Test_Increments_MaxRate(t *testing.T) {
+	records := []ambient.Record{
+		record(t, 0, ambient.Record{Dailyrainin: 0.1}),
+		record(t, 5, ambient.Record{Dailyrainin: 3.1}),
+		record(t, 10, ambient.Record{Dailyrainin: 3.2}),
+	}
+
+	increments := Increments(records)
+	require.Len(t, increments, 2)
+	require.True(t, increments[0].Jump)
+	require.Equal(t, 0.0, increments[0].Rain)
+	require.InDelta(t, 0.1, increments[1].Rain, 1e-9)
+	require.InDelta(t, 0.1, Total(increments), 1e-9)
+}
+
+func Test_Storms(t *testing.T) {
+	var records []ambient.Record
+	daily := 0.0
+	for minutes := 0; minutes <= 16*60; minutes += 30 {
+		// Rain for two hours, then for an hour seven hours later.
+		if (minutes > 0 && minutes <= 120) || (minutes > 540 && minutes <= 600) {
+			daily += 0.1
+		}
+		fields := ambient.Record{Dailyrainin: daily}
+		if minutes == 120 {
+			fields.LastRain = start.Add(110 * time.Minute)
+		}
+		records = append(records, record(t, minutes, fields, "dailyrainin"))
+	}
+
+	increments := Increments(records)
+	require.InDelta(t, 0.6, Total(increments), 1e-9)
+
+	storms := Storms(increments, 0)
+	require.Len(t, storms, 2)
+	require.Equal(t, start, storms[0].Start)
+	require.Equal(t, start.Add(110*time.Minute), storms[0].End)
+	require.Equal(t, 110*time.Minute, storms[0].Duration())
+	require.InDelta(t, 0.4, storms[0].Rain, 1e-9)
+	require.InDelta(t, 0.2, storms[0].PeakRate, 1e-9)
+	require.Equal(t, start.Add(540*time.Minute), storms[1].Start)
+	require.Equal(t, start.Add(600*time.Minute), storms[1].End)
+	require.InDelta(t, 0.2, storms[1].Rain, 1e-9)
+
+	// A longer gap merges them.
+	require.Len(t, Storms(increments, 8*time.Hour), 1)
+}
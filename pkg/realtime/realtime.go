@@ -0,0 +1,506 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+// Package realtime provides a client for ambientweather.net's
+// realtime API which is documented at
+//
+//	https://ambientweather.docs.apiary.io/#reference/ambient-realtime-api
+//
+// The realtime API is a socket.io server: once connected, the client
+// subscribes with apiKeys and receives a subscribed event listing the
+// devices, then a data event for every new observation.  This package
+// speaks the Engine.IO v4 long-polling transport, so it needs nothing
+// beyond net/http.
+package realtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+)
+
+// DefaultURL is the realtime endpoint.
+const DefaultURL = "https://rt2.ambientweather.net"
+
+// Reconnection back off defaults.
+const (
+	DefaultMinBackoff = 1 * time.Second
+	DefaultMaxBackoff = 1 * time.Minute
+)
+
+// ErrConnectRefused is returned when the server refuses the
+// socket.io connection, e.g. for an invalid applicationKey.
+var ErrConnectRefused = errors.New("realtime: connection refused")
+
+// ErrRunCalled is returned when Run is called more than once.
+var ErrRunCalled = errors.New("realtime: Run already called")
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+// Event types.
+const (
+	// EventConnected is sent once connected, before subscribing.
+	EventConnected EventType = "connect"
+	// EventSubscribed lists the devices of the subscribed apiKeys.
+	EventSubscribed EventType = "subscribed"
+	// EventData carries a new observation of one device.
+	EventData EventType = "data"
+	// EventDisconnected is sent when the connection is lost;
+	// the client then reconnects on its own.
+	EventDisconnected EventType = "disconnect"
+)
+
+// Event is a message received from the realtime API.
+type Event struct {
+	Type EventType
+	// MacAddress, Record and Fields are set for EventData.
+	// Fields holds every field as sent by the API.
+	MacAddress string
+	Record     ambient.Record
+	Fields     map[string]interface{}
+	// Devices and InvalidAPIKeys are set for EventSubscribed.
+	Devices        []ambient.DeviceRecord
+	InvalidAPIKeys []string
+	// Err is the cause of an EventDisconnected.
+	Err error
+}
+
+// Client is a realtime API client for one applicationKey.
+// Its methods are safe for concurrent use.
+type Client struct {
+	applicationKey string
+	baseURL        string
+	httpClient     *http.Client
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	events         chan Event
+
+	mu      sync.Mutex
+	apiKeys map[string]bool
+	session *session
+	ran     bool
+}
+
+// String returns a representation of the Client without its keys,
+// so that it can safely be logged.
+func (c *Client) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fmt.Sprintf("Client{url: %s, apiKeys: %d}", c.baseURL, len(c.apiKeys))
+}
+
+// GoString implements fmt.GoStringer, leaving out the keys for %#v.
+func (c *Client) GoString() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fmt.Sprintf("&realtime.Client{url:%q, apiKeys:%d}", c.baseURL, len(c.apiKeys))
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithURL sets the realtime endpoint.  It defaults to DefaultURL.
+func WithURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithHTTPClient sets the *http.Client used for the long-polling requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithBackoff sets the bounds of the exponential back off
+// between two reconnection attempts.
+func WithBackoff(min, max time.Duration) Option {
+	return func(c *Client) {
+		c.minBackoff, c.maxBackoff = min, max
+	}
+}
+
+// WithBuffer sets how many events may be queued before
+// the client stops reading from the server.  It defaults to 64.
+func WithBuffer(size int) Option {
+	return func(c *Client) {
+		c.events = make(chan Event, size)
+	}
+}
+
+// NewClient returns a Client for applicationKey.
+func NewClient(applicationKey string, opts ...Option) *Client {
+	c := &Client{
+		applicationKey: applicationKey,
+		baseURL:        DefaultURL,
+		httpClient:     http.DefaultClient,
+		minBackoff:     DefaultMinBackoff,
+		maxBackoff:     DefaultMaxBackoff,
+		events:         make(chan Event, 64),
+		apiKeys:        make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.httpClient == nil {
+		c.httpClient = http.DefaultClient
+	}
+	if c.minBackoff <= 0 {
+		c.minBackoff = DefaultMinBackoff
+	}
+	if c.maxBackoff < c.minBackoff {
+		c.maxBackoff = c.minBackoff
+	}
+	return c
+}
+
+// Events returns the channel on which events are delivered.
+// It is closed when Run returns.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Subscribe adds apiKeys to the subscription.  The subscription is
+// sent right away when connected, and again after each reconnection.
+func (c *Client) Subscribe(ctx context.Context, apiKeys ...string) error {
+	c.mu.Lock()
+	for _, apiKey := range apiKeys {
+		c.apiKeys[apiKey] = true
+	}
+	s := c.session
+	c.mu.Unlock()
+	if s == nil {
+		return nil
+	}
+	return s.emit(ctx, "subscribe", apiKeys)
+}
+
+// Unsubscribe removes apiKeys from the subscription.
+func (c *Client) Unsubscribe(ctx context.Context, apiKeys ...string) error {
+	c.mu.Lock()
+	for _, apiKey := range apiKeys {
+		delete(c.apiKeys, apiKey)
+	}
+	s := c.session
+	c.mu.Unlock()
+	if s == nil {
+		return nil
+	}
+	return s.emit(ctx, "unsubscribe", apiKeys)
+}
+
+// subscribed returns the apiKeys currently subscribed.
+func (c *Client) subscribed() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	apiKeys := make([]string, 0, len(c.apiKeys))
+	for apiKey := range c.apiKeys {
+		apiKeys = append(apiKeys, apiKey)
+	}
+	return apiKeys
+}
+
+// Run connects to the realtime API and delivers events until ctx is
+// done, reconnecting with exponential back off whenever the connection
+// is lost.  It returns ctx.Err().  Run closes the Events channel on
+// return, so it may only be called once; later calls return
+// ErrRunCalled.
+func (c *Client) Run(ctx context.Context) error {
+	c.mu.Lock()
+	ran := c.ran
+	c.ran = true
+	c.mu.Unlock()
+	if ran {
+		return ErrRunCalled
+	}
+	defer close(c.events)
+	backoff := c.minBackoff
+	for {
+		connected, err := c.runSession(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if connected {
+			backoff = c.minBackoff
+		}
+		if !c.deliver(ctx, Event{Type: EventDisconnected, Err: err}) {
+			return ctx.Err()
+		}
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+// deliver queues e, reporting false if ctx is done first.
+func (c *Client) deliver(ctx context.Context, e Event) bool {
+	select {
+	case c.events <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// runSession runs one Engine.IO session until it fails, reporting
+// whether the socket.io connection was established.
+func (c *Client) runSession(ctx context.Context) (bool, error) {
+	s, err := c.handshake(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		c.mu.Lock()
+		if c.session == s {
+			c.session = nil
+		}
+		c.mu.Unlock()
+	}()
+	if err := s.send(ctx, socketMessage+socketConnect); err != nil {
+		return false, err
+	}
+
+	connected := false
+	for {
+		packets, err := s.poll(ctx)
+		if err != nil {
+			return connected, err
+		}
+		for _, packet := range packets {
+			var err error
+			switch {
+			case packet == enginePing:
+				err = s.send(ctx, enginePong)
+			case packet == engineClose:
+				return connected, errors.New("realtime: closed by server")
+			case strings.HasPrefix(packet, socketMessage+socketConnect):
+				connected = true
+				c.mu.Lock()
+				c.session = s
+				c.mu.Unlock()
+				if !c.deliver(ctx, Event{Type: EventConnected}) {
+					return connected, ctx.Err()
+				}
+				if apiKeys := c.subscribed(); len(apiKeys) > 0 {
+					err = s.emit(ctx, "subscribe", apiKeys)
+				}
+			case strings.HasPrefix(packet, socketMessage+socketDisconnect):
+				return connected, errors.New("realtime: disconnected by server")
+			case strings.HasPrefix(packet, socketMessage+socketConnectError):
+				return connected, fmt.Errorf("%w: %s", ErrConnectRefused, packet[2:])
+			case strings.HasPrefix(packet, socketMessage+socketEvent):
+				var e Event
+				var ok bool
+				e, ok, err = decodeEvent(packet[2:])
+				if ok && !c.deliver(ctx, e) {
+					return connected, ctx.Err()
+				}
+			}
+			if err != nil {
+				return connected, err
+			}
+		}
+	}
+}
+
+// decodeEvent decodes the socket.io event payload `["name",data]`,
+// reporting false for events the API does not document.
+func decodeEvent(payload string) (Event, bool, error) {
+	var args []json.RawMessage
+	if err := json.Unmarshal([]byte(payload), &args); err != nil || len(args) == 0 {
+		return Event{}, false, fmt.Errorf("realtime: bad event %q", payload)
+	}
+	var name string
+	if err := json.Unmarshal(args[0], &name); err != nil {
+		return Event{}, false, fmt.Errorf("realtime: bad event %q", payload)
+	}
+	var data json.RawMessage
+	if len(args) > 1 {
+		data = args[1]
+	}
+	// A field of an unexpected type, such as a decimal for an int
+	// Record field, is left zero rather than losing the event; its
+	// value is still available in Fields.
+	var typeErr *json.UnmarshalTypeError
+	switch EventType(name) {
+	case EventData:
+		e := Event{Type: EventData}
+		if err := json.Unmarshal(data, &e.Record); err != nil && !errors.As(err, &typeErr) {
+			return Event{}, false, err
+		}
+		if err := json.Unmarshal(data, &e.Fields); err != nil {
+			return Event{}, false, err
+		}
+		e.MacAddress, _ = e.Fields["macAddress"].(string)
+		return e, true, nil
+	case EventSubscribed:
+		var subscribed struct {
+			Devices        []ambient.DeviceRecord `json:"devices"`
+			InvalidAPIKeys []string               `json:"invalidApiKeys"`
+		}
+		if err := json.Unmarshal(data, &subscribed); err != nil && !errors.As(err, &typeErr) {
+			return Event{}, false, err
+		}
+		return Event{
+			Type: EventSubscribed, Devices: subscribed.Devices, InvalidAPIKeys: subscribed.InvalidAPIKeys,
+		}, true, nil
+	}
+	return Event{}, false, nil
+}
+
+// Engine.IO v4 and socket.io v5 packet types.
+const (
+	engineOpen    = "0"
+	engineClose   = "1"
+	enginePing    = "2"
+	enginePong    = "3"
+	socketMessage = "4"
+
+	socketConnect      = "0"
+	socketDisconnect   = "1"
+	socketEvent        = "2"
+	socketConnectError = "4"
+
+	// packetSeparator separates the packets of a polling payload.
+	packetSeparator = "\x1e"
+)
+
+// session is one Engine.IO long-polling session.
+type session struct {
+	client       *Client
+	sid          string
+	pingInterval time.Duration
+	pingTimeout  time.Duration
+
+	// sendMu serializes POSTs, which Engine.IO forbids to overlap.
+	sendMu sync.Mutex
+}
+
+// handshake opens a new Engine.IO session.
+func (c *Client) handshake(ctx context.Context) (*session, error) {
+	s := &session{client: c}
+	packets, err := s.request(ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(packets) == 0 || !strings.HasPrefix(packets[0], engineOpen) {
+		return nil, fmt.Errorf("realtime: unexpected handshake %q", packets)
+	}
+	var open struct {
+		SID          string `json:"sid"`
+		PingInterval int    `json:"pingInterval"`
+		PingTimeout  int    `json:"pingTimeout"`
+	}
+	if err := json.Unmarshal([]byte(packets[0][1:]), &open); err != nil {
+		return nil, fmt.Errorf("realtime: bad handshake: %w", err)
+	}
+	s.sid = open.SID
+	s.pingInterval = time.Duration(open.PingInterval) * time.Millisecond
+	s.pingTimeout = time.Duration(open.PingTimeout) * time.Millisecond
+	return s, nil
+}
+
+// poll waits for the next packets from the server.  The server pings
+// every pingInterval, so a poll lasting longer than pingInterval plus
+// pingTimeout means the connection is dead.
+func (s *session) poll(ctx context.Context) ([]string, error) {
+	if s.pingInterval > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.pingInterval+s.pingTimeout)
+		defer cancel()
+	}
+	return s.request(ctx, http.MethodGet, nil)
+}
+
+// emit sends the socket.io event name with {"apiKeys": apiKeys}.
+func (s *session) emit(ctx context.Context, name string, apiKeys []string) error {
+	payload, err := json.Marshal([]interface{}{name, map[string][]string{"apiKeys": apiKeys}})
+	if err != nil {
+		return err
+	}
+	return s.send(ctx, socketMessage+socketEvent+string(payload))
+}
+
+// send POSTs packet to the server.
+func (s *session) send(ctx context.Context, packet string) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	_, err := s.request(ctx, http.MethodPost, []byte(packet))
+	return err
+}
+
+// request issues one polling request and splits the payload returned.
+func (s *session) request(ctx context.Context, method string, body []byte) ([]string, error) {
+	query := url.Values{
+		"EIO":            {"4"},
+		"transport":      {"polling"},
+		"api":            {"1"},
+		"applicationKey": {s.client.applicationKey},
+	}
+	if s.sid != "" {
+		query.Set("sid", s.sid)
+	}
+	endpoint := s.client.baseURL + "/socket.io/?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, redactError(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/plain;charset=UTF-8")
+	}
+	resp, err := s.client.httpClient.Do(req)
+	if err != nil {
+		return nil, redactError(err)
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("realtime: %s returned %d: %s", method, resp.StatusCode, bytes.TrimSpace(payload))
+	}
+	if method != http.MethodGet || len(payload) == 0 {
+		return nil, nil
+	}
+	return strings.Split(string(payload), packetSeparator), nil
+}
+
+// redactError masks the applicationKey in the URL carried by err.
+func redactError(err error) error {
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return err
+	}
+	redactedURL := "REDACTED"
+	if u, perr := url.Parse(urlErr.URL); perr == nil {
+		q := u.Query()
+		q.Set("applicationKey", "REDACTED")
+		u.RawQuery = q.Encode()
+		redactedURL = u.String()
+	}
+	return &url.Error{Op: urlErr.Op, URL: redactedURL, Err: urlErr.Err}
+}
@@ -0,0 +1,289 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer is an in-process socket.io server speaking the
+// Engine.IO v4 polling transport like the realtime API.
+type fakeServer struct {
+	t            *testing.T
+	refuse       bool
+	pingInterval time.Duration
+
+	mu         sync.Mutex
+	sessions   map[string]chan string
+	handshakes int
+	emitted    []string
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, *httptest.Server) {
+	f := &fakeServer{t: t, pingInterval: 100 * time.Millisecond, sessions: make(map[string]chan string)}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	require.Equal(f.t, "/socket.io/", r.URL.Path)
+	require.Equal(f.t, "4", query.Get("EIO"))
+	require.Equal(f.t, "polling", query.Get("transport"))
+	require.Equal(f.t, "application-key", query.Get("applicationKey"))
+
+	sid := query.Get("sid")
+	f.mu.Lock()
+	queue, ok := f.sessions[sid]
+	if sid == "" {
+		f.handshakes++
+		sid = fmt.Sprintf("sid-%d", f.handshakes)
+		f.sessions[sid] = make(chan string, 16)
+	}
+	f.mu.Unlock()
+
+	switch {
+	case query.Get("sid") == "":
+		_, _ = fmt.Fprintf(w, `0{"sid":%q,"upgrades":[],"pingInterval":%d,"pingTimeout":%d}`,
+			sid, f.pingInterval.Milliseconds(), f.pingInterval.Milliseconds())
+	case !ok:
+		http.Error(w, `{"code":1,"message":"Session ID unknown"}`, http.StatusBadRequest)
+	case r.Method == http.MethodPost:
+		body, _ := io.ReadAll(r.Body)
+		for _, packet := range strings.Split(string(body), packetSeparator) {
+			f.handle(queue, packet)
+		}
+		_, _ = w.Write([]byte("ok"))
+	default:
+		packets := []string{enginePing}
+		select {
+		case packet := <-queue:
+			packets = []string{packet}
+			for len(queue) > 0 {
+				packets = append(packets, <-queue)
+			}
+		case <-time.After(f.pingInterval):
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = w.Write([]byte(strings.Join(packets, packetSeparator)))
+	}
+}
+
+func (f *fakeServer) handle(queue chan string, packet string) {
+	switch {
+	case packet == "40":
+		if f.refuse {
+			queue <- `44{"message":"invalid applicationKey"}`
+			return
+		}
+		queue <- `40{"sid":"socket"}`
+	case strings.HasPrefix(packet, "42"):
+		var args []json.RawMessage
+		require.Nil(f.t, json.Unmarshal([]byte(packet[2:]), &args))
+		f.mu.Lock()
+		f.emitted = append(f.emitted, packet[2:])
+		f.mu.Unlock()
+		var name string
+		require.Nil(f.t, json.Unmarshal(args[0], &name))
+		if name == "subscribe" {
+			queue <- `42["subscribed",{"method":"subscribe","devices":[{"macAddress":"00:0E:C6:10:01:86","info":{"name":"Backyard"}}],"invalidApiKeys":["bad-key"]}]`
+			queue <- `42["data",{"macAddress":"00:0E:C6:10:01:86","date":"2023-01-02T03:04:05.000Z","tempf":71.2,"humidity":40,"leak1":0}]`
+		}
+	}
+}
+
+// closeSessions makes the server drop every session.
+func (f *fakeServer) closeSessions() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for sid, queue := range f.sessions {
+		queue <- engineClose
+		delete(f.sessions, sid)
+	}
+}
+
+func (f *fakeServer) emittedEvents() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.emitted...)
+}
+
+func nextEvent(t *testing.T, events <-chan Event, eventType EventType) Event {
+	for {
+		select {
+		case e, ok := <-events:
+			require.True(t, ok, "events closed waiting for %s", eventType)
+			if e.Type == eventType {
+				return e
+			}
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for "+string(eventType))
+		}
+	}
+}
+
+func startClient(t *testing.T, server *httptest.Server) (*Client, context.CancelFunc, chan error) {
+	client := NewClient("application-key", WithURL(server.URL), WithBackoff(10*time.Millisecond, 50*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Run(ctx)
+	}()
+	t.Cleanup(cancel)
+	return client, cancel, done
+}
+
+func Test_Client_SubscribeBeforeRun_ReceivesSubscribedAndData(t *testing.T) {
+	_, server := newFakeServer(t)
+	client := NewClient("application-key", WithURL(server.URL))
+	require.Nil(t, client.Subscribe(context.Background(), "api-key"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = client.Run(ctx)
+	}()
+
+	nextEvent(t, client.Events(), EventConnected)
+	subscribed := nextEvent(t, client.Events(), EventSubscribed)
+	data := nextEvent(t, client.Events(), EventData)
+
+	require.Len(t, subscribed.Devices, 1)
+	require.Equal(t, "00:0E:C6:10:01:86", subscribed.Devices[0].Macaddress)
+	require.Equal(t, "Backyard", subscribed.Devices[0].Info.Name)
+	require.Equal(t, []string{"bad-key"}, subscribed.InvalidAPIKeys)
+	require.Equal(t, "00:0E:C6:10:01:86", data.MacAddress)
+	require.Equal(t, 71.2, data.Record.Tempf)
+	require.Equal(t, 40, data.Record.Humidity)
+	require.Equal(t, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), data.Record.Date.UTC())
+	require.Equal(t, float64(0), data.Fields["leak1"])
+}
+
+func Test_Client_SubscribeWhileConnected_EmitsSubscribe(t *testing.T) {
+	fake, server := newFakeServer(t)
+	client, _, _ := startClient(t, server)
+
+	nextEvent(t, client.Events(), EventConnected)
+	require.Nil(t, client.Subscribe(context.Background(), "api-key"))
+	nextEvent(t, client.Events(), EventSubscribed)
+	require.Nil(t, client.Unsubscribe(context.Background(), "api-key"))
+
+	require.Equal(t, []string{
+		`["subscribe",{"apiKeys":["api-key"]}]`,
+		`["unsubscribe",{"apiKeys":["api-key"]}]`,
+	}, fake.emittedEvents())
+}
+
+func Test_Client_ConnectionLost_ReconnectsAndResubscribes(t *testing.T) {
+	fake, server := newFakeServer(t)
+	client, _, _ := startClient(t, server)
+	require.Nil(t, client.Subscribe(context.Background(), "api-key"))
+
+	nextEvent(t, client.Events(), EventSubscribed)
+	fake.closeSessions()
+	disconnected := nextEvent(t, client.Events(), EventDisconnected)
+	nextEvent(t, client.Events(), EventConnected)
+	nextEvent(t, client.Events(), EventSubscribed)
+
+	require.NotNil(t, disconnected.Err)
+	require.Len(t, fake.emittedEvents(), 2)
+}
+
+func Test_Client_AnswersPings(t *testing.T) {
+	fake, server := newFakeServer(t)
+	fake.pingInterval = 10 * time.Millisecond
+	client, _, _ := startClient(t, server)
+
+	nextEvent(t, client.Events(), EventConnected)
+	time.Sleep(100 * time.Millisecond)
+	require.Nil(t, client.Subscribe(context.Background(), "api-key"))
+	nextEvent(t, client.Events(), EventSubscribed)
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	require.Equal(t, 1, fake.handshakes)
+}
+
+func Test_Client_ConnectRefused_ReportsError(t *testing.T) {
+	fake, server := newFakeServer(t)
+	fake.refuse = true
+	client, _, _ := startClient(t, server)
+
+	disconnected := nextEvent(t, client.Events(), EventDisconnected)
+
+	require.True(t, errors.Is(disconnected.Err, ErrConnectRefused))
+}
+
+func Test_Client_Run_StopsOnCancel(t *testing.T) {
+	_, server := newFakeServer(t)
+	client, cancel, done := startClient(t, server)
+
+	nextEvent(t, client.Events(), EventConnected)
+	cancel()
+
+	select {
+	case err := <-done:
+		require.True(t, errors.Is(err, context.Canceled))
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Run did not return")
+	}
+	for range client.Events() {
+	}
+}
+
+func Test_Client_Run_SecondCall_ReturnsError(t *testing.T) {
+	_, server := newFakeServer(t)
+	client, cancel, done := startClient(t, server)
+
+	nextEvent(t, client.Events(), EventConnected)
+	require.Equal(t, ErrRunCalled, client.Run(context.Background()))
+	cancel()
+	require.True(t, errors.Is(<-done, context.Canceled))
+	require.Equal(t, ErrRunCalled, client.Run(context.Background()))
+}
+
+func Test_Client_String_HidesKeys(t *testing.T) {
+	client := NewClient("21a439e927a84a25bb79ffe894fdd372", WithURL("http://localhost"))
+	client.apiKeys["78f9704baaab411a87edeed59052cbb6"] = true
+
+	for _, format := range []string{"%s", "%v", "%+v", "%#v"} {
+		formatted := fmt.Sprintf(format, client)
+		require.NotContains(t, formatted, "21a439e927a84a25", format)
+		require.NotContains(t, formatted, "78f9704baaab411a", format)
+		require.Contains(t, formatted, "apiKeys", format)
+	}
+}
+
+func Test_decodeEvent_UnknownEvent_IsIgnored(t *testing.T) {
+	_, ok, err := decodeEvent(`["news",{}]`)
+
+	require.Nil(t, err)
+	require.False(t, ok)
+}
+
+func Test_decodeEvent_UnexpectedType_DeliversEvent(t *testing.T) {
+	e, ok, err := decodeEvent(`["data",{"macAddress":"00:0E:C6:10:01:86","tempf":71.2,"humidity":41.5}]`)
+
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "00:0E:C6:10:01:86", e.MacAddress)
+	require.Equal(t, 71.2, e.Record.Tempf)
+	require.Equal(t, 41.5, e.Fields["humidity"])
+}
+
+func Test_decodeEvent_Malformed_ReturnsError(t *testing.T) {
+	_, _, err := decodeEvent(`{"data":1}`)
+
+	require.NotNil(t, err)
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+package receiver
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// EcowittHandler returns an http.Handler accepting "Customized Server"
+// uploads in the Ecowitt protocol, e.g.
+//
+//	POST /data/report/
+//	PASSKEY=8E2B4E6C5B0AAD6F12C7D3F9D6EFB1B5&stationtype=GW1000B_V1.6.8&dateutc=2023-01-02+03:04:05&tempinf=72.1&soilmoisture1=31&wh65batt=0
+//
+// and calling fn with each of them.  Ecowitt fields are stored in Record
+// under the name of the matching API field, with battery indications
+// converted to the API's convention where it differs.  Ecowitt consoles
+// send an MD5 hash as PASSKEY, so MacAddress holds that hash.
+func EcowittHandler(fn func(Upload)) http.Handler {
+	return &handler{fields: ecowittFields, fn: fn, now: time.Now}
+}
+
+// ecowittFields maps Ecowitt fields named differently from the API, and
+// those of ambientFields.  batt1 to batt8 (WH31) share the API's name
+// and convention, 0 OK and 1 low.  soilbatt1 to soilbatt8 (WH51) report
+// a voltage, for which Record has no field, and are left unmapped.
+var ecowittFields = func() map[string]field {
+	fields := map[string]field{
+		"rainratein":       {key: "hourlyrainin"},
+		"pm25_ch1":         {key: "pm25"},
+		"pm25_avg_24h_ch1": {key: "pm25_24h"},
+		"pm25_co2":         {key: "pm25_in"},
+		"pm25_24h_co2":     {key: "pm25_in_24h"},
+		"co2":              {key: "co2_in"},
+		"co2_24h":          {key: "co2_in_24h"},
+		"lightning":        {key: "lightning_distance"},
+		"lightning_num":    {key: "lightning_day"},
+		"wh65batt":         {key: "battout", convert: lowToOK},
+		"wh26batt":         {key: "battout", convert: lowToOK, yieldTo: "wh65batt"},
+		"wh25batt":         {key: "battin", convert: lowToOK},
+		"wh57batt":         {key: "batt_lightning", convert: levelToLow},
+		"co2_batt":         {key: "batt_co2", convert: levelToOK},
+		"pm25batt1":        {key: "batt_25", convert: levelToOK},
+	}
+	for name, field := range ambientFields {
+		fields[name] = field
+	}
+	for i := 1; i <= 4; i++ {
+		n := strconv.Itoa(i)
+		fields["leak_ch"+n] = field{key: "leak" + n}
+		fields["leakbatt"+n] = field{key: "batt_leak" + n, convert: levelToLow}
+	}
+	for i := 1; i <= 8; i++ {
+		n := strconv.Itoa(i)
+		fields["soilmoisture"+n] = field{key: "soilhum" + n}
+		fields["tf_ch"+n] = field{key: "soiltemp" + n + "f"}
+	}
+	return fields
+}()
+
+// epochTime converts Unix seconds to the API's time format.
+func epochTime(value float64) interface{} {
+	return time.Unix(int64(value), 0).UTC().Format(time.RFC3339)
+}
+
+// lowToOK converts an Ecowitt low battery flag (0 OK, 1 low)
+// to the API's OK flag (1 OK, 0 low).
+func lowToOK(value float64) interface{} {
+	if value == 0 {
+		return 1
+	}
+	return 0
+}
+
+// levelToOK converts an Ecowitt battery level (0 to 5, 6 on
+// external power) to the API's OK flag (1 OK, 0 low).
+func levelToOK(value float64) interface{} {
+	if value > 1 {
+		return 1
+	}
+	return 0
+}
+
+// levelToLow converts an Ecowitt battery level to the
+// API's low battery flag (1 low, 0 OK).
+func levelToLow(value float64) interface{} {
+	return 1 - levelToOK(value).(int)
+}
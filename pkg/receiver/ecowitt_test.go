@@ -0,0 +1,146 @@
+package receiver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const ecowittUpload = "PASSKEY=8E2B4E6C5B0AAD6F12C7D3F9D6EFB1B5&stationtype=GW1000B_V1.6.8&runtime=289633" +
+	"&dateutc=2023-01-02+03:04:05&tempinf=72.1&humidityin=44&baromrelin=29.947&baromabsin=29.947" +
+	"&tempf=41.4&humidity=79&winddir=198&windspeedmph=2.2&windgustmph=4.5&maxdailygust=9.2" +
+	"&rainratein=0.012&eventrainin=0.020&dailyrainin=0.020&totalrainin=3.421&solarradiation=12.53&uv=0" +
+	"&temp1f=68.2&humidity1=47&batt1=0&soilmoisture1=31&soilmoisture2=48&soilbatt1=1.5&tf_ch1=45.3" +
+	"&pm25_ch1=7.0&pm25_avg_24h_ch1=9.5&pm25batt1=5&pm25_ch2=3.0" +
+	"&lightning=12&lightning_num=3&lightning_time=1672628645&wh57batt=1" +
+	"&wh65batt=0&wh25batt=1&co2=612&co2_batt=6&pm25_co2=4.2&pm25_24h_co2=5.1&leak_ch2=1&leakbatt2=1" +
+	"&freq=915M&model=GW1000_Pro"
+
+func serveEcowittUpload(body string) (*httptest.ResponseRecorder, []Upload) {
+	var uploads []Upload
+	handler := &handler{
+		fields: ecowittFields,
+		fn:     func(upload Upload) { uploads = append(uploads, upload) },
+		now:    func() time.Time { return receptionTime },
+	}
+	request := httptest.NewRequest(http.MethodPost, "/data/report/", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder, uploads
+}
+
+func Test_EcowittHandler_ParsesUpload(t *testing.T) {
+	recorder, uploads := serveEcowittUpload(ecowittUpload)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Len(t, uploads, 1)
+	upload := uploads[0]
+	require.Equal(t, "8E2B4E6C5B0AAD6F12C7D3F9D6EFB1B5", upload.MacAddress)
+	require.Equal(t, "GW1000B_V1.6.8", upload.StationType)
+	require.Equal(t, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), upload.Record.Date)
+	require.Equal(t, 72.1, upload.Record.Tempinf)
+	require.Equal(t, 44, upload.Record.Humidityin)
+	require.Equal(t, 29.947, upload.Record.Baromrelin)
+	require.Equal(t, 41.4, upload.Record.Tempf)
+	require.Equal(t, 198, upload.Record.Winddir)
+	require.Equal(t, 0.012, upload.Record.Hourlyrainin)
+	require.Equal(t, 3.421, upload.Record.Totalrainin)
+	require.Equal(t, 68.2, upload.Record.Temp1f)
+	require.Equal(t, 31.0, upload.Record.Soilhum1)
+	require.Equal(t, 48.0, upload.Record.Soilhum2)
+	require.Equal(t, 45.3, upload.Record.Soiltemp1f)
+	require.Equal(t, 7.0, upload.Record.Pm25)
+	require.Equal(t, 9.5, upload.Record.Pm25_24h)
+	require.Equal(t, 612.0, upload.Record.Co2_in)
+	require.Equal(t, 4.2, upload.Record.Pm25_in)
+	require.Equal(t, 5.1, upload.Record.Pm25_in_24h)
+	require.Equal(t, 1, upload.Record.Leak2)
+	require.True(t, upload.Record.Has("leak2"))
+	require.False(t, upload.Record.Has("leak1"))
+	require.Equal(t, 12.0, upload.Record.Lightning_distance)
+	require.Equal(t, 3, upload.Record.Lightning_day)
+	require.Equal(t, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), upload.Record.Lightning_time)
+}
+
+func Test_EcowittHandler_ConvertsBatteries(t *testing.T) {
+	_, uploads := serveEcowittUpload(ecowittUpload)
+
+	require.Len(t, uploads, 1)
+	record := uploads[0].Record
+	require.Equal(t, "1", record.Battout.String())
+	require.Equal(t, "0", record.Battin.String())
+	require.Equal(t, "0", record.Batt1.String())
+	require.Equal(t, "1", record.Batt_lightning.String())
+	require.Equal(t, "1", record.Batt_co2.String())
+	require.Equal(t, "1", record.Batt_25.String())
+	require.Equal(t, "1", record.Batt_leak2.String())
+	require.True(t, record.Has("batt_leak2"))
+	require.False(t, record.Has("batt_leak1"))
+}
+
+func Test_EcowittHandler_WH65BatteryWins(t *testing.T) {
+	_, uploads := serveEcowittUpload("PASSKEY=8E2B4E6C5B0AAD6F12C7D3F9D6EFB1B5&wh26batt=1&wh65batt=0")
+
+	require.Len(t, uploads, 1)
+	require.Equal(t, "1", uploads[0].Record.Battout.String())
+	require.Equal(t, 1.0, uploads[0].Unmapped["wh26batt"])
+
+	_, uploads = serveEcowittUpload("PASSKEY=8E2B4E6C5B0AAD6F12C7D3F9D6EFB1B5&wh26batt=1")
+
+	require.Len(t, uploads, 1)
+	require.Equal(t, "0", uploads[0].Record.Battout.String())
+}
+
+func Test_EcowittHandler_KeepsUnmappedFields(t *testing.T) {
+	_, uploads := serveEcowittUpload(ecowittUpload)
+
+	require.Len(t, uploads, 1)
+	upload := uploads[0]
+	require.Equal(t, map[string]interface{}{
+		"runtime":   289633.0,
+		"soilbatt1": 1.5,
+		"pm25_ch2":  3.0,
+		"freq":      "915M",
+		"model":     "GW1000_Pro",
+	}, upload.Unmapped)
+	require.Equal(t, 0.0, upload.Fields["wh65batt"])
+	require.Equal(t, 31.0, upload.Fields["soilmoisture1"])
+}
+
+func Test_EcowittHandler_WH45(t *testing.T) {
+	// The WH45 is an indoor PM2.5, PM10 and CO2 sensor.
+	_, uploads := serveEcowittUpload("PASSKEY=8E2B4E6C5B0AAD6F12C7D3F9D6EFB1B5&dateutc=2023-01-02+03:04:05" +
+		"&tf_co2=71.6&humi_co2=45&pm25_co2=4.2&pm25_24h_co2=5.1&pm10_co2=6.3&pm10_24h_co2=7.4" +
+		"&co2=612&co2_24h=587&co2_batt=6")
+
+	require.Len(t, uploads, 1)
+	record := uploads[0].Record
+	require.Equal(t, 4.2, record.Pm25_in)
+	require.Equal(t, 5.1, record.Pm25_in_24h)
+	require.Equal(t, 612.0, record.Co2_in)
+	require.Equal(t, 587.0, record.Co2_in_24h)
+	require.False(t, record.Has("co2"))
+	require.Equal(t, "1", record.Batt_co2.String())
+}
+
+func Test_EcowittHandler_EmptyLightningTime_IsSkipped(t *testing.T) {
+	recorder, uploads := serveEcowittUpload("PASSKEY=8E2B4E6C5B0AAD6F12C7D3F9D6EFB1B5&lightning_time=&tempf=50")
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Len(t, uploads, 1)
+	require.True(t, uploads[0].Record.Lightning_time.IsZero())
+	require.Equal(t, 50.0, uploads[0].Record.Tempf)
+}
+
+func Test_EcowittHandler_APIFieldWins(t *testing.T) {
+	_, uploads := serveEcowittUpload("PASSKEY=8E2B4E6C5B0AAD6F12C7D3F9D6EFB1B5&rainratein=0.5&hourlyrainin=0.25")
+
+	require.Len(t, uploads, 1)
+	require.Equal(t, 0.25, uploads[0].Record.Hourlyrainin)
+	require.Equal(t, 0.5, uploads[0].Unmapped["rainratein"])
+}
@@ -0,0 +1,218 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+// Package receiver collects observations pushed by weather station
+// consoles on the local network, without going through the cloud.
+//
+// Consoles such as the WS-2902 and WS-5000 can upload to a
+// "Customized Server" using the Ambient Weather query-string protocol,
+// handled by AmbientHandler.  Ecowitt gateways upload the same sensors
+// with their own field names, handled by EcowittHandler.
+package receiver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+)
+
+// Upload is one observation pushed by a console.
+type Upload struct {
+	// MacAddress identifies the console, formatted like
+	// DeviceRecord.Macaddress when PassKey holds a MAC address.
+	MacAddress string
+	// PassKey is the PASSKEY sent by the console.
+	PassKey string
+	// StationType is the model and firmware reported by the console.
+	StationType string
+	// Record is the observation.
+	Record ambient.Record
+	// Fields holds every field uploaded, under its uploaded name,
+	// numbers as float64, like DeviceRecord.LastDataFields.
+	Fields map[string]interface{}
+	// Unmapped holds the fields of Fields that have no
+	// counterpart in Record.
+	Unmapped map[string]interface{}
+	// RemoteAddr is the network address of the console.
+	RemoteAddr string
+}
+
+// uploadDateLayout is the layout of dateutc in uploads.
+const uploadDateLayout = "2006-01-02 15:04:05"
+
+// handler implements AmbientHandler and EcowittHandler.
+type handler struct {
+	fields map[string]field
+	fn     func(Upload)
+	now    func() time.Time
+}
+
+// field tells how an uploaded field is stored in Record.
+type field struct {
+	// key is the name of the field in the API.
+	key string
+	// convert, if set, converts the uploaded value.
+	convert func(value float64) interface{}
+	// yieldTo, if set, is an uploaded field stored under the
+	// same key, which takes precedence when both are sent.
+	yieldTo string
+}
+
+// ambientFields maps the fields of both protocols that
+// are converted to be stored in Record.
+var ambientFields = map[string]field{
+	"lightning_time": {key: "lightning_time", convert: epochTime},
+}
+
+// AmbientHandler returns an http.Handler accepting "Customized Server"
+// uploads in the Ambient Weather protocol, e.g.
+//
+//	GET /data/?PASSKEY=000EC6100186&stationtype=AMBWeatherV4.2.9&dateutc=2019-09-11+16:48:49&tempf=82.4&humidity=41
+//
+// and calling fn with each of them.
+func AmbientHandler(fn func(Upload)) http.Handler {
+	return &handler{fields: ambientFields, fn: fn, now: time.Now}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	values, err := uploadValues(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	upload, err := parseUpload(values, h.fields, h.now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	upload.RemoteAddr = r.RemoteAddr
+	h.fn(upload)
+	_, _ = w.Write([]byte("success\n"))
+}
+
+// uploadValues returns the fields of an upload.  Some firmware appends
+// them to the configured path without a '?', as in /data&PASSKEY=...,
+// so they are looked for in the path as well.
+func uploadValues(r *http.Request) (url.Values, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	values := r.Form
+	if len(values) == 0 {
+		if i := strings.IndexAny(r.URL.Path, "&?"); i >= 0 {
+			var err error
+			if values, err = url.ParseQuery(r.URL.Path[i+1:]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if values.Get("PASSKEY") == "" {
+		return nil, errors.New("receiver: missing PASSKEY")
+	}
+	return values, nil
+}
+
+// parseUpload converts the uploaded values to an Upload.  Fields
+// found in fields are stored as described there, the others under
+// their uploaded name.
+func parseUpload(values url.Values, fields map[string]field, now func() time.Time) (Upload, error) {
+	upload := Upload{
+		PassKey:     values.Get("PASSKEY"),
+		StationType: values.Get("stationtype"),
+		Fields:      make(map[string]interface{}, len(values)),
+		Unmapped:    make(map[string]interface{}),
+	}
+	upload.MacAddress = NormalizeMac(upload.PassKey)
+
+	date, err := parseUploadDate(values.Get("dateutc"), now)
+	if err != nil {
+		return upload, err
+	}
+	recordFields := make(map[string]interface{}, len(values))
+	recordFields["date"] = date
+	recordFields["dateutc"] = date.UnixMilli()
+	for name := range values {
+		value := values.Get(name)
+		var raw interface{} = value
+		number, err := strconv.ParseFloat(value, 64)
+		if err == nil {
+			raw = number
+		}
+		upload.Fields[name] = raw
+		switch name {
+		case "PASSKEY", "stationtype", "dateutc":
+			continue
+		}
+		mapped, ok := fields[name]
+		if !ok {
+			mapped = field{key: name}
+		}
+		if _, ok := ambient.LookupField(mapped.key); !ok || (mapped.key != name && values.Has(mapped.key)) ||
+			(mapped.yieldTo != "" && values.Has(mapped.yieldTo)) {
+			upload.Unmapped[name] = raw
+			continue
+		}
+		if mapped.convert != nil {
+			if err != nil {
+				// Consoles send an empty value for sensors without
+				// a reading yet, e.g. lightning_time.
+				continue
+			}
+			raw = mapped.convert(number)
+		}
+		recordFields[mapped.key] = raw
+	}
+
+	data, err := json.Marshal(recordFields)
+	if err != nil {
+		return upload, err
+	}
+	// A field sent as a decimal for an int Record field is left zero
+	// in Record; its value is still available in Fields.
+	var typeErr *json.UnmarshalTypeError
+	if err := json.Unmarshal(data, &upload.Record); err != nil && !errors.As(err, &typeErr) {
+		return upload, err
+	}
+	return upload, nil
+}
+
+// parseUploadDate parses dateutc, which consoles without
+// a clock send as "now".
+func parseUploadDate(dateutc string, now func() time.Time) (time.Time, error) {
+	if dateutc == "" || dateutc == "now" {
+		return now().UTC().Truncate(time.Second), nil
+	}
+	if date, err := time.Parse(uploadDateLayout, dateutc); err == nil {
+		return date, nil
+	}
+	if epoch, err := strconv.ParseInt(dateutc, 10, 64); err == nil {
+		return time.Unix(epoch, 0).UTC(), nil
+	}
+	return time.Time{}, errors.New("receiver: bad dateutc " + strconv.Quote(dateutc))
+}
+
+var macPassKey = regexp.MustCompile(`^[0-9A-Fa-f]{2}([:-]?[0-9A-Fa-f]{2}){5}$`)
+
+// NormalizeMac formats passKey like DeviceRecord.Macaddress
+// (00:0E:C6:10:01:86) when it is a MAC address, with or without
+// separators, and returns it unchanged otherwise.
+func NormalizeMac(passKey string) string {
+	if !macPassKey.MatchString(passKey) {
+		return passKey
+	}
+	hex := strings.ToUpper(strings.NewReplacer(":", "", "-", "").Replace(passKey))
+	pairs := make([]string, 0, 6)
+	for i := 0; i < len(hex); i += 2 {
+		pairs = append(pairs, hex[i:i+2])
+	}
+	return strings.Join(pairs, ":")
+}
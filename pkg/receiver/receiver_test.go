@@ -0,0 +1,136 @@
+package receiver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const ambientUpload = "PASSKEY=000EC6100186&stationtype=AMBWeatherV4.2.9&dateutc=2019-09-11+16:48:49" +
+	"&tempinf=76.6&humidityin=46&baromrelin=29.879&baromabsin=29.456&tempf=82.4&battout=1&humidity=41" +
+	"&winddir=234&windspeedmph=0.9&windgustmph=1.1&maxdailygust=5.8&hourlyrainin=0.000&eventrainin=0.000" +
+	"&dailyrainin=0.010&weeklyrainin=0.020&monthlyrainin=0.280&totalrainin=43.079&solarradiation=331.57" +
+	"&uv=3&batt_co2=1&temp1f=70.3&humidity1=55&batt1=1"
+
+var receptionTime = time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+
+func serveAmbientUpload(target string) (*httptest.ResponseRecorder, []Upload) {
+	var uploads []Upload
+	handler := &handler{
+		fields: ambientFields,
+		fn:     func(upload Upload) { uploads = append(uploads, upload) },
+		now:    func() time.Time { return receptionTime },
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	return recorder, uploads
+}
+
+func Test_AmbientHandler_ParsesUpload(t *testing.T) {
+	recorder, uploads := serveAmbientUpload("/data/?" + ambientUpload)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Len(t, uploads, 1)
+	upload := uploads[0]
+	require.Equal(t, "00:0E:C6:10:01:86", upload.MacAddress)
+	require.Equal(t, "000EC6100186", upload.PassKey)
+	require.Equal(t, "AMBWeatherV4.2.9", upload.StationType)
+	require.Equal(t, time.Date(2019, 9, 11, 16, 48, 49, 0, time.UTC), upload.Record.Date)
+	require.Equal(t, upload.Record.Date.UnixMilli(), upload.Record.Dateutc)
+	require.Equal(t, 82.4, upload.Record.Tempf)
+	require.Equal(t, 76.6, upload.Record.Tempinf)
+	require.Equal(t, 41, upload.Record.Humidity)
+	require.Equal(t, 46, upload.Record.Humidityin)
+	require.Equal(t, 29.879, upload.Record.Baromrelin)
+	require.Equal(t, 29.456, upload.Record.Baromabsin)
+	require.Equal(t, 234, upload.Record.Winddir)
+	require.Equal(t, 0.9, upload.Record.Windspeedmph)
+	require.Equal(t, 5.8, upload.Record.Maxdailygust)
+	require.Equal(t, 0.01, upload.Record.Dailyrainin)
+	require.Equal(t, 43.079, upload.Record.Totalrainin)
+	require.Equal(t, 331.57, upload.Record.Solarradiation)
+	require.Equal(t, float64(3), upload.Record.Uv)
+	require.Equal(t, 70.3, upload.Record.Temp1f)
+	require.Equal(t, 55, upload.Record.Humidity1)
+	require.Equal(t, "1", upload.Record.Battout.String())
+	require.Equal(t, "1", upload.Record.Batt_co2.String())
+	require.Equal(t, 82.4, upload.Fields["tempf"])
+	require.Equal(t, "000EC6100186", upload.Fields["PASSKEY"])
+}
+
+func Test_AmbientHandler_UploadAppendedToPath(t *testing.T) {
+	recorder, uploads := serveAmbientUpload("/data/report&" + ambientUpload)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Len(t, uploads, 1)
+	require.Equal(t, 82.4, uploads[0].Record.Tempf)
+}
+
+func Test_AmbientHandler_DateNow_UsesReceptionTime(t *testing.T) {
+	_, uploads := serveAmbientUpload("/?PASSKEY=000EC6100186&dateutc=now&tempf=50")
+
+	require.Len(t, uploads, 1)
+	require.Equal(t, receptionTime.Truncate(time.Second), uploads[0].Record.Date)
+}
+
+func Test_AmbientHandler_ConvertsLightningTime(t *testing.T) {
+	_, uploads := serveAmbientUpload("/?PASSKEY=000EC6100186&lightning_time=1672628645&lightning_day=3")
+
+	require.Len(t, uploads, 1)
+	require.Equal(t, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), uploads[0].Record.Lightning_time)
+	require.Equal(t, 3, uploads[0].Record.Lightning_day)
+}
+
+func Test_AmbientHandler_DecimalForIntField_KeepsOtherFields(t *testing.T) {
+	_, uploads := serveAmbientUpload("/?PASSKEY=000EC6100186&humidity=41.5&tempf=50")
+
+	require.Len(t, uploads, 1)
+	require.Equal(t, 50.0, uploads[0].Record.Tempf)
+	require.Zero(t, uploads[0].Record.Humidity)
+	require.Equal(t, 41.5, uploads[0].Fields["humidity"])
+}
+
+func Test_AmbientHandler_MissingPassKey_ReturnsBadRequest(t *testing.T) {
+	recorder, uploads := serveAmbientUpload("/?tempf=50")
+
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.Empty(t, uploads)
+}
+
+func Test_AmbientHandler_BadDate_ReturnsBadRequest(t *testing.T) {
+	recorder, uploads := serveAmbientUpload("/?PASSKEY=000EC6100186&dateutc=yesterday")
+
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.Empty(t, uploads)
+}
+
+func Test_NormalizeMac(t *testing.T) {
+	tests := []struct {
+		passKey  string
+		expected string
+	}{
+		{"000EC6100186", "00:0E:C6:10:01:86"},
+		{"000ec6100186", "00:0E:C6:10:01:86"},
+		{"00:0e:c6:10:01:86", "00:0E:C6:10:01:86"},
+		{"00-0E-C6-10-01-86", "00:0E:C6:10:01:86"},
+		{"8E2B4E6C5B0AAD6F12C7D3F9D6EFB1B5", "8E2B4E6C5B0AAD6F12C7D3F9D6EFB1B5"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, NormalizeMac(tt.passKey), tt.passKey)
+	}
+}
+
+func Test_AmbientHandler_TracksPresence(t *testing.T) {
+	_, uploads := serveAmbientUpload("/?PASSKEY=000EC6100186&tempf=0&uv=0")
+
+	require.Len(t, uploads, 1)
+	require.True(t, uploads[0].Record.Has("date"))
+	require.True(t, uploads[0].Record.Has("tempf"))
+	require.True(t, uploads[0].Record.Has("uv"))
+	require.False(t, uploads[0].Record.Has("humidity"))
+}
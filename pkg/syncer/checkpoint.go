@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CheckpointStore persists, per device, the date up to which its
+// records were written to the Sink.
+type CheckpointStore interface {
+	// Load returns the checkpoint of mac, and false if there is none.
+	Load(ctx context.Context, mac string) (time.Time, bool, error)
+	// Save records checkpoint as the checkpoint of mac.
+	Save(ctx context.Context, mac string, checkpoint time.Time) error
+}
+
+// MemoryCheckpoints is a CheckpointStore kept in memory.
+// The zero value is ready to use.
+type MemoryCheckpoints struct {
+	mu          sync.Mutex
+	checkpoints map[string]time.Time
+}
+
+// Load implements CheckpointStore.
+func (m *MemoryCheckpoints) Load(_ context.Context, mac string) (time.Time, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	checkpoint, ok := m.checkpoints[mac]
+	return checkpoint, ok, nil
+}
+
+// Save implements CheckpointStore.
+func (m *MemoryCheckpoints) Save(_ context.Context, mac string, checkpoint time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.checkpoints == nil {
+		m.checkpoints = make(map[string]time.Time)
+	}
+	m.checkpoints[mac] = checkpoint
+	return nil
+}
+
+// FileCheckpoints is a CheckpointStore kept in a JSON file.
+// Every Save rewrites the file atomically, so a crash never
+// leaves it half written.
+type FileCheckpoints struct {
+	path string
+
+	mu sync.Mutex
+}
+
+// NewFileCheckpoints returns a CheckpointStore kept in the file at path,
+// which is created on the first Save.
+func NewFileCheckpoints(path string) *FileCheckpoints {
+	return &FileCheckpoints{path: path}
+}
+
+// Load implements CheckpointStore.
+func (f *FileCheckpoints) Load(_ context.Context, mac string) (time.Time, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	checkpoints, err := f.read()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	checkpoint, ok := checkpoints[mac]
+	return checkpoint, ok, nil
+}
+
+// Save implements CheckpointStore.
+func (f *FileCheckpoints) Save(_ context.Context, mac string, checkpoint time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	checkpoints, err := f.read()
+	if err != nil {
+		return err
+	}
+	checkpoints[mac] = checkpoint
+	data, err := json.MarshalIndent(checkpoints, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+func (f *FileCheckpoints) read() (map[string]time.Time, error) {
+	checkpoints := make(map[string]time.Time)
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return checkpoints, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &checkpoints); err != nil {
+		return nil, err
+	}
+	return checkpoints, nil
+}
@@ -0,0 +1,58 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FileCheckpoints_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.json")
+	checkpoint := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	store := NewFileCheckpoints(path)
+	_, ok, err := store.Load(context.Background(), "AA")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	require.Nil(t, store.Save(context.Background(), "AA", checkpoint))
+	require.Nil(t, store.Save(context.Background(), "BB", checkpoint.Add(time.Hour)))
+
+	reopened := NewFileCheckpoints(path)
+	loaded, ok, err := reopened.Load(context.Background(), "AA")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.True(t, checkpoint.Equal(loaded))
+	loaded, ok, err = reopened.Load(context.Background(), "BB")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.True(t, checkpoint.Add(time.Hour).Equal(loaded))
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+}
+
+func Test_FileCheckpoints_CorruptFile_ReturnsError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.json")
+	require.Nil(t, os.WriteFile(path, []byte("{"), 0o600))
+
+	_, _, err := NewFileCheckpoints(path).Load(context.Background(), "AA")
+
+	require.NotNil(t, err)
+}
+
+func Test_MemoryCheckpoints_ZeroValue(t *testing.T) {
+	var store MemoryCheckpoints
+
+	_, ok, err := store.Load(context.Background(), "AA")
+	require.Nil(t, err)
+	require.False(t, ok)
+	require.Nil(t, store.Save(context.Background(), "AA", time.Unix(0, 0)))
+	_, ok, _ = store.Load(context.Background(), "AA")
+	require.True(t, ok)
+}
@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+// Package syncer keeps a local copy of the history of every device
+// of an account up to date.
+//
+// Each run lists the devices with Device and downloads, oldest first,
+// the records newer than a per-device checkpoint.  Records are handed
+// to a Sink one window at a time and the checkpoint is only saved once
+// the Sink has accepted them, so a run interrupted at any point resumes
+// where the previous one stopped without leaving gaps.
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+)
+
+// DefaultWindow is the span of history written to the Sink at once.
+const DefaultWindow = 24 * time.Hour
+
+// DefaultLag is how old a window without any record must be before
+// the checkpoint moves past it, leaving the API time to ingest records
+// that reach it late.
+const DefaultLag = time.Hour
+
+// DefaultLookback is how far back devices without a checkpoint
+// are synced unless WithStart is used.
+const DefaultLookback = 24 * time.Hour
+
+// Sink receives the records of one device, oldest first.
+//
+// A crash between Write and the checkpoint being saved makes the next
+// run deliver the same records again, so Write must be idempotent for
+// a given mac and Record.Date.
+type Sink interface {
+	Write(ctx context.Context, mac string, records []ambient.Record) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, mac string, records []ambient.Record) error
+
+// Write calls f.
+func (f SinkFunc) Write(ctx context.Context, mac string, records []ambient.Record) error {
+	return f(ctx, mac, records)
+}
+
+// Progress reports the state of the sync of one device.
+type Progress struct {
+	// Mac is the device being synced.
+	Mac string
+	// Written is the number of records written for Mac during this run.
+	Written int
+	// Checkpoint is the date up to which Mac is synced, that of the
+	// newest record written or the end of a later window without any
+	// that is older than the lag.
+	Checkpoint time.Time
+	// Done is set once Mac is up to date.
+	Done bool
+}
+
+// Syncer syncs the devices of one Key.
+type Syncer struct {
+	client      *ambient.Client
+	key         ambient.Key
+	sink        Sink
+	checkpoints CheckpointStore
+	start       time.Time
+	window      time.Duration
+	lag         time.Duration
+	progress    func(Progress)
+	now         func() time.Time
+}
+
+// String returns a representation of the Syncer with its Key
+// masked, so that it can safely be logged.
+func (s Syncer) String() string {
+	return "Syncer{key: " + s.key.String() + ", window: " + s.window.String() + "}"
+}
+
+// GoString implements fmt.GoStringer, masking the Key for %#v.
+func (s Syncer) GoString() string {
+	return "syncer.Syncer{key:" + s.key.GoString() + ", window:" + strconv.Quote(s.window.String()) + "}"
+}
+
+// Option configures a Syncer.
+type Option func(*Syncer)
+
+// WithStart sets the date from which devices without a checkpoint
+// are synced.  It defaults to DefaultLookback ago.
+func WithStart(start time.Time) Option {
+	return func(s *Syncer) {
+		s.start = start
+	}
+}
+
+// WithWindow sets the span of history written to the Sink at once.
+func WithWindow(window time.Duration) Option {
+	return func(s *Syncer) {
+		s.window = window
+	}
+}
+
+// WithLag sets how old a window without any record must be before
+// the checkpoint moves past it.  It defaults to DefaultLag.
+func WithLag(lag time.Duration) Option {
+	return func(s *Syncer) {
+		s.lag = lag
+	}
+}
+
+// WithProgress sets a function called after every write
+// and once each device is up to date.
+func WithProgress(progress func(Progress)) Option {
+	return func(s *Syncer) {
+		s.progress = progress
+	}
+}
+
+// New returns a Syncer fetching the devices of key through client,
+// writing their records to sink and tracking them in checkpoints.
+func New(client *ambient.Client, key ambient.Key, sink Sink, checkpoints CheckpointStore, opts ...Option) *Syncer {
+	s := &Syncer{
+		client:      client,
+		key:         key,
+		sink:        sink,
+		checkpoints: checkpoints,
+		window:      DefaultWindow,
+		lag:         DefaultLag,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.client == nil {
+		s.client = ambient.DefaultClient
+	}
+	if s.window <= 0 {
+		s.window = DefaultWindow
+	}
+	if s.lag < 0 {
+		s.lag = 0
+	}
+	return s
+}
+
+// Run brings every device up to date.  It stops at the first error;
+// calling Run again resumes from the saved checkpoints.
+func (s *Syncer) Run(ctx context.Context) error {
+	devices, err := s.client.DeviceContext(ctx, s.key)
+	if err != nil {
+		return fmt.Errorf("syncer: listing devices: %w", err)
+	}
+	for _, device := range devices.DeviceRecord {
+		if err := s.SyncDevice(ctx, device.Macaddress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncDevice brings the device mac up to date.
+func (s *Syncer) SyncDevice(ctx context.Context, mac string) error {
+	now := s.now()
+	checkpoint, ok, err := s.checkpoints.Load(ctx, mac)
+	if err != nil {
+		return fmt.Errorf("syncer: %s: loading checkpoint: %w", mac, err)
+	}
+	if !ok {
+		checkpoint = s.start
+		if checkpoint.IsZero() {
+			checkpoint = now.Add(-DefaultLookback)
+		}
+	}
+
+	written := 0
+	for windowStart := checkpoint; windowStart.Before(now); windowStart = windowStart.Add(s.window) {
+		windowEnd := windowStart.Add(s.window)
+		settled := !windowEnd.After(now.Add(-s.lag))
+		if windowEnd.After(now) {
+			windowEnd = now
+		}
+		records, err := s.fetch(ctx, mac, checkpoint, windowStart, windowEnd)
+		if err != nil {
+			return fmt.Errorf("syncer: %s: %w", mac, err)
+		}
+		if len(records) == 0 {
+			// Move past an empty window older than the lag,
+			// such as one while the device was offline, so
+			// that the next run does not fetch it again.  A
+			// recent one may still receive records the API
+			// ingests late.
+			if settled {
+				checkpoint = windowEnd
+				if err := s.checkpoints.Save(ctx, mac, checkpoint); err != nil {
+					return fmt.Errorf("syncer: %s: saving checkpoint: %w", mac, err)
+				}
+			}
+			continue
+		}
+		if err := s.sink.Write(ctx, mac, records); err != nil {
+			return fmt.Errorf("syncer: %s: writing records: %w", mac, err)
+		}
+		checkpoint = records[len(records)-1].Date
+		if err := s.checkpoints.Save(ctx, mac, checkpoint); err != nil {
+			return fmt.Errorf("syncer: %s: saving checkpoint: %w", mac, err)
+		}
+		written += len(records)
+		s.report(Progress{Mac: mac, Written: written, Checkpoint: checkpoint})
+	}
+	s.report(Progress{Mac: mac, Written: written, Checkpoint: checkpoint, Done: true})
+	return nil
+}
+
+// fetch returns the records of mac in [from, to] newer than
+// checkpoint, oldest first.
+func (s *Syncer) fetch(ctx context.Context, mac string, checkpoint, from, to time.Time) ([]ambient.Record, error) {
+	var records []ambient.Record
+	it := s.client.HistoryRange(ctx, s.key, mac, from, to)
+	for it.Next() {
+		if record := it.Record(); record.Date.After(checkpoint) {
+			records = append(records, record)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+func (s *Syncer) report(progress Progress) {
+	if s.progress != nil {
+		s.progress(progress)
+	}
+}
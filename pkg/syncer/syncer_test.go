@@ -0,0 +1,261 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lrosenman/ambient/pkg/ambient"
+	"github.com/stretchr/testify/require"
+)
+
+var errSinkFailed = errors.New("sink failed")
+
+// fakeAPI serves two devices whose records are 5 minutes
+// apart up to the station clock last.
+type fakeAPI struct {
+	mu    sync.Mutex
+	last  time.Time
+	first time.Time
+	// calls counts the requests for records.
+	calls int
+}
+
+func (f *fakeAPI) setLast(last time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.last = last
+}
+
+// takeCalls returns the requests for records since the last call.
+func (f *fakeAPI) takeCalls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	calls := f.calls
+	f.calls = 0
+	return calls
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/devices" {
+		_ = json.NewEncoder(w).Encode([]map[string]string{{"macAddress": "AA"}, {"macAddress": "BB"}})
+		return
+	}
+	f.calls++
+	endDate, _ := time.Parse(time.RFC3339, r.URL.Query().Get("endDate"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	mac := strings.TrimPrefix(r.URL.Path, "/devices/")
+	page := make([]map[string]interface{}, 0, limit)
+	for date := f.last; !date.Before(f.first) && len(page) < limit; date = date.Add(-5 * time.Minute) {
+		if !date.After(endDate) {
+			page = append(page, map[string]interface{}{"date": date, "tempf": 50, "mac": mac})
+		}
+	}
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+// memorySink stores records per device, rejecting duplicates
+// like a table keyed on mac and date would.
+type memorySink struct {
+	records map[string][]ambient.Record
+	failAt  int
+	writes  int
+}
+
+func (m *memorySink) Write(_ context.Context, mac string, records []ambient.Record) error {
+	m.writes++
+	if m.writes == m.failAt {
+		return errSinkFailed
+	}
+	if m.records == nil {
+		m.records = make(map[string][]ambient.Record)
+	}
+	for _, record := range records {
+		existing := m.records[mac]
+		if len(existing) > 0 && !record.Date.After(existing[len(existing)-1].Date) {
+			return errors.New("out of order or duplicate record")
+		}
+		m.records[mac] = append(existing, record)
+	}
+	return nil
+}
+
+func requireContiguous(t *testing.T, records []ambient.Record, from, to time.Time) {
+	require.NotEmpty(t, records)
+	require.Equal(t, from, records[0].Date.UTC())
+	require.Equal(t, to, records[len(records)-1].Date.UTC())
+	for i := 1; i < len(records); i++ {
+		require.Equal(t, 5*time.Minute, records[i].Date.Sub(records[i-1].Date))
+	}
+}
+
+func newTestSyncer(server *httptest.Server, sink Sink, checkpoints CheckpointStore, now time.Time, opts ...Option) *Syncer {
+	client := ambient.NewClient(ambient.WithBaseURL(server.URL), ambient.WithRateLimiter(nil))
+	s := New(client, ambient.NewKey("app", "api"), sink, checkpoints, opts...)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func Test_Syncer_Run_BackfillsThenSyncsNewRecords(t *testing.T) {
+	first := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	api := &fakeAPI{first: first, last: first.Add(3 * 24 * time.Hour)}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	sink := &memorySink{}
+	checkpoints := &MemoryCheckpoints{}
+	var progress []Progress
+	start := first.Add(time.Hour)
+	s := newTestSyncer(server, sink, checkpoints, api.last, WithStart(start), WithWindow(12*time.Hour),
+		WithProgress(func(p Progress) { progress = append(progress, p) }))
+
+	require.Nil(t, s.Run(context.Background()))
+
+	for _, mac := range []string{"AA", "BB"} {
+		requireContiguous(t, sink.records[mac], start.Add(5*time.Minute), api.last)
+		checkpoint, ok, err := checkpoints.Load(context.Background(), mac)
+		require.Nil(t, err)
+		require.True(t, ok)
+		require.Equal(t, api.last, checkpoint.UTC())
+	}
+	require.True(t, progress[len(progress)-1].Done)
+	require.Equal(t, len(sink.records["BB"]), progress[len(progress)-1].Written)
+
+	newLast := api.last.Add(2 * time.Hour)
+	api.setLast(newLast)
+	s.now = func() time.Time { return newLast }
+
+	require.Nil(t, s.Run(context.Background()))
+
+	for _, mac := range []string{"AA", "BB"} {
+		requireContiguous(t, sink.records[mac], start.Add(5*time.Minute), newLast)
+	}
+}
+
+func Test_Syncer_Run_SkipsEmptyWindowsOnNextRun(t *testing.T) {
+	first := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	// The devices went offline a day before now.
+	api := &fakeAPI{first: first, last: first.Add(24 * time.Hour)}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	sink := &memorySink{}
+	checkpoints := &MemoryCheckpoints{}
+	now := api.last.Add(24 * time.Hour)
+	// Starting two days before the first record.
+	s := newTestSyncer(server, sink, checkpoints, now, WithStart(first.Add(-48*time.Hour)), WithWindow(12*time.Hour))
+
+	require.Nil(t, s.Run(context.Background()))
+	require.NotZero(t, api.takeCalls())
+	for _, mac := range []string{"AA", "BB"} {
+		requireContiguous(t, sink.records[mac], first, api.last)
+		checkpoint, _, err := checkpoints.Load(context.Background(), mac)
+		require.Nil(t, err)
+		// The window ending now is within the lag.
+		require.Equal(t, now.Add(-12*time.Hour), checkpoint.UTC())
+	}
+
+	// Only the window within the lag is fetched again.
+	require.Nil(t, s.Run(context.Background()))
+	require.Equal(t, 2, api.takeCalls())
+}
+
+func Test_Syncer_Run_WritesRecordsIngestedLate(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	// No record has reached the API yet.
+	api := &fakeAPI{first: start.Add(6 * time.Hour), last: start}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	sink := &memorySink{}
+	checkpoints := &MemoryCheckpoints{}
+	now := start.Add(12*time.Hour + 30*time.Minute)
+	s := newTestSyncer(server, sink, checkpoints, now, WithStart(start), WithWindow(12*time.Hour))
+
+	require.Nil(t, s.Run(context.Background()))
+	for _, mac := range []string{"AA", "BB"} {
+		require.Empty(t, sink.records[mac])
+		_, ok, err := checkpoints.Load(context.Background(), mac)
+		require.Nil(t, err)
+		require.False(t, ok)
+	}
+
+	// The records of the first window reach the API late.
+	api.setLast(start.Add(12 * time.Hour))
+
+	require.Nil(t, s.Run(context.Background()))
+	for _, mac := range []string{"AA", "BB"} {
+		requireContiguous(t, sink.records[mac], api.first, api.last)
+	}
+}
+
+func Test_Syncer_Run_ResumesAfterFailureWithoutGaps(t *testing.T) {
+	first := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	api := &fakeAPI{first: first, last: first.Add(2 * 24 * time.Hour)}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	sink := &memorySink{failAt: 3}
+	checkpoints := &MemoryCheckpoints{}
+	s := newTestSyncer(server, sink, checkpoints, api.last, WithStart(first), WithWindow(6*time.Hour))
+
+	err := s.Run(context.Background())
+	require.True(t, errors.Is(err, errSinkFailed))
+	require.Contains(t, err.Error(), "AA")
+
+	require.Nil(t, s.Run(context.Background()))
+
+	for _, mac := range []string{"AA", "BB"} {
+		requireContiguous(t, sink.records[mac], first.Add(5*time.Minute), api.last)
+	}
+}
+
+func Test_Syncer_Run_DefaultLookback(t *testing.T) {
+	first := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	api := &fakeAPI{first: first, last: first.Add(3 * 24 * time.Hour)}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	sink := &memorySink{}
+	s := newTestSyncer(server, sink, &MemoryCheckpoints{}, api.last)
+
+	require.Nil(t, s.Run(context.Background()))
+
+	requireContiguous(t, sink.records["AA"], api.last.Add(-DefaultLookback).Add(5*time.Minute), api.last)
+}
+
+func Test_Syncer_Run_DeviceError_ReturnsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	s := newTestSyncer(server, &memorySink{}, &MemoryCheckpoints{}, time.Now())
+	err := s.Run(context.Background())
+
+	require.True(t, errors.Is(err, ambient.ErrUnauthorized))
+}
+
+func Test_Syncer_String_MasksKey(t *testing.T) {
+	key := ambient.NewKey("21a439e927a84a25bb79ffe894fdd372", "78f9704baaab411a87edeed59052cbb6")
+	s := New(nil, key, &memorySink{}, &MemoryCheckpoints{})
+
+	for _, format := range []string{"%s", "%v", "%+v", "%#v"} {
+		for _, value := range []interface{}{s, *s} {
+			formatted := fmt.Sprintf(format, value)
+			require.NotContains(t, formatted, "21a439e927a84a25", format)
+			require.NotContains(t, formatted, "78f9704baaab411a", format)
+			require.Contains(t, formatted, "cbb6", format)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+// SPDX-License-Identifier: BSD-2-Clause
+//
+// Copyright 2018 Larry Rosenman, LERCTR Consulting, larryrtx@gmail.com
+//
+
+// Package units converts and formats the measurements reported by the
+// Ambient Weather API, which are all in US customary units.
+//
+// A UnitSystem selects the unit used for each Quantity:
+//
+//	Quantity     US      Metric  MetricWx  UK
+//	Temperature  °F      °C      °C        °C
+//	Pressure     inHg    hPa     hPa       hPa
+//	Rain         in      mm      mm        mm
+//	RainRate     in/h    mm/h    mm/h      mm/h
+//	WindSpeed    mph     km/h    m/s       mph
+//	Distance     mi      km      km        mi
+//	Radiation    W/m²    W/m²    W/m²      W/m²
+//
+// Other quantities, such as Humidity or Direction, are reported
+// in the same unit in every system.
+package units
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Quantity is the kind of physical quantity a Unit measures.
+type Quantity string
+
+const (
+	Temperature Quantity = "temperature"
+	Pressure    Quantity = "pressure"
+	Rain        Quantity = "rain"
+	RainRate    Quantity = "rain rate"
+	WindSpeed   Quantity = "wind speed"
+	Distance    Quantity = "distance"
+	Radiation   Quantity = "radiation"
+	Humidity    Quantity = "humidity"
+	Direction   Quantity = "direction"
+	UVIndex     Quantity = "uv index"
+	// MassConcentration is the mass of particulates in a volume of
+	// air, such as PM2.5, and MixingRatio the fraction of a gas in
+	// air, such as CO2.  One does not convert to the other.
+	MassConcentration Quantity = "mass concentration"
+	MixingRatio       Quantity = "mixing ratio"
+	AirQuality        Quantity = "air quality"
+	Count             Quantity = "count"
+	State             Quantity = "state"
+)
+
+// Unit is a unit of measurement.  The zero value, None,
+// is used for values without a unit.
+type Unit int
+
+const (
+	None Unit = iota
+	Fahrenheit
+	Celsius
+	InHg
+	HPa
+	MmHg
+	Inch
+	Millimeter
+	InchPerHour
+	MillimeterPerHour
+	MilesPerHour
+	KilometersPerHour
+	MetersPerSecond
+	Knots
+	Mile
+	Kilometer
+	WattsPerSquareMeter
+	Percent
+	Degree
+	Index
+	AQI
+	MicrogramsPerCubicMeter
+	PartsPerMillion
+	Strikes
+	Flag
+)
+
+// unitInfo describes each Unit.  A value is converted to the base unit
+// of its quantity (the one with scale 1 and offset 0) as
+// value*scale + offset.
+var unitInfo = [...]struct {
+	symbol   string
+	quantity Quantity
+	scale    float64
+	offset   float64
+	decimals int
+}{
+	None:                    {"", "", 1, 0, -1},
+	Fahrenheit:              {"°F", Temperature, 5.0 / 9, -32 * 5.0 / 9, 1},
+	Celsius:                 {"°C", Temperature, 1, 0, 1},
+	InHg:                    {"inHg", Pressure, 33.8638866667, 0, 2},
+	HPa:                     {"hPa", Pressure, 1, 0, 1},
+	MmHg:                    {"mmHg", Pressure, 1.33322387415, 0, 1},
+	Inch:                    {"in", Rain, 25.4, 0, 2},
+	Millimeter:              {"mm", Rain, 1, 0, 1},
+	InchPerHour:             {"in/h", RainRate, 25.4, 0, 2},
+	MillimeterPerHour:       {"mm/h", RainRate, 1, 0, 1},
+	MilesPerHour:            {"mph", WindSpeed, 0.44704, 0, 1},
+	KilometersPerHour:       {"km/h", WindSpeed, 1 / 3.6, 0, 1},
+	MetersPerSecond:         {"m/s", WindSpeed, 1, 0, 1},
+	Knots:                   {"kn", WindSpeed, 1852 / 3600.0, 0, 1},
+	Mile:                    {"mi", Distance, 1.609344, 0, 1},
+	Kilometer:               {"km", Distance, 1, 0, 1},
+	WattsPerSquareMeter:     {"W/m²", Radiation, 1, 0, 0},
+	Percent:                 {"%", Humidity, 1, 0, 0},
+	Degree:                  {"°", Direction, 1, 0, 0},
+	Index:                   {"", UVIndex, 1, 0, 0},
+	AQI:                     {"AQI", AirQuality, 1, 0, 0},
+	MicrogramsPerCubicMeter: {"µg/m³", MassConcentration, 1, 0, 1},
+	PartsPerMillion:         {"ppm", MixingRatio, 1, 0, 0},
+	Strikes:                 {"", Count, 1, 0, 0},
+	Flag:                    {"", State, 1, 0, 0},
+}
+
+// Symbol returns the symbol of u, such as "°F", or ""
+// for dimensionless units.
+func (u Unit) Symbol() string {
+	if !u.valid() {
+		return ""
+	}
+	return unitInfo[u].symbol
+}
+
+// Quantity returns the quantity measured by u.
+func (u Unit) Quantity() Quantity {
+	if !u.valid() {
+		return ""
+	}
+	return unitInfo[u].quantity
+}
+
+// String returns the symbol of u, or its name for dimensionless units.
+func (u Unit) String() string {
+	switch u {
+	case None:
+		return "none"
+	case Index:
+		return "index"
+	case Strikes:
+		return "strikes"
+	case Flag:
+		return "flag"
+	}
+	if !u.valid() {
+		return "Unit(" + strconv.Itoa(int(u)) + ")"
+	}
+	return u.Symbol()
+}
+
+func (u Unit) valid() bool {
+	return u >= 0 && int(u) < len(unitInfo)
+}
+
+// ErrIncompatible is returned when converting between
+// units of different quantities.
+var ErrIncompatible = errors.New("units: incompatible units")
+
+// Convert converts value from one unit to another of the same quantity.
+func Convert(value float64, from, to Unit) (float64, error) {
+	if from == to {
+		return value, nil
+	}
+	if !from.valid() || !to.valid() || from.Quantity() != to.Quantity() || from.Quantity() == "" {
+		return value, fmt.Errorf("%w: %v to %v", ErrIncompatible, from, to)
+	}
+	f, t := unitInfo[from], unitInfo[to]
+	return (value*f.scale + f.offset - t.offset) / t.scale, nil
+}
+
+// UnitSystem selects the units measurements are reported in.
+type UnitSystem int
+
+const (
+	// US uses °F, inHg, in, mph and miles, the units of the API
+	// except for distances.
+	US UnitSystem = iota
+	// Metric uses °C, hPa, mm, km/h and km.
+	Metric
+	// MetricWx is Metric with wind speeds in m/s.
+	MetricWx
+	// UK is Metric with wind speeds in mph and distances in miles.
+	UK
+)
+
+var systemNames = [...]string{US: "us", Metric: "metric", MetricWx: "metricwx", UK: "uk"}
+
+// systemUnits holds the unit of each quantity that depends on the system.
+var systemUnits = map[Quantity][len(systemNames)]Unit{
+	Temperature: {Fahrenheit, Celsius, Celsius, Celsius},
+	Pressure:    {InHg, HPa, HPa, HPa},
+	Rain:        {Inch, Millimeter, Millimeter, Millimeter},
+	RainRate:    {InchPerHour, MillimeterPerHour, MillimeterPerHour, MillimeterPerHour},
+	WindSpeed:   {MilesPerHour, KilometersPerHour, MetersPerSecond, MilesPerHour},
+	Distance:    {Mile, Kilometer, Kilometer, Mile},
+	Radiation:   {WattsPerSquareMeter, WattsPerSquareMeter, WattsPerSquareMeter, WattsPerSquareMeter},
+}
+
+// ParseUnitSystem returns the UnitSystem named name, as
+// returned by String, ignoring case.
+func ParseUnitSystem(name string) (UnitSystem, error) {
+	for system, systemName := range systemNames {
+		if strings.EqualFold(name, systemName) {
+			return UnitSystem(system), nil
+		}
+	}
+	return US, fmt.Errorf("units: unknown unit system %q", name)
+}
+
+// String returns the name of s.
+func (s UnitSystem) String() string {
+	if s < 0 || int(s) >= len(systemNames) {
+		return "UnitSystem(" + strconv.Itoa(int(s)) + ")"
+	}
+	return systemNames[s]
+}
+
+// Unit returns the unit s uses for quantity q, or None
+// when q is reported in the same unit in every system.
+func (s UnitSystem) Unit(q Quantity) Unit {
+	if s < 0 || int(s) >= len(systemNames) {
+		return None
+	}
+	return systemUnits[q][s]
+}
+
+// Value is a measurement in a given unit.
+type Value struct {
+	Value float64
+	Unit  Unit
+}
+
+// In returns v converted to the unit s uses for its quantity.
+func (v Value) In(s UnitSystem) Value {
+	to := s.Unit(v.Unit.Quantity())
+	if to == None {
+		return v
+	}
+	converted, _ := v.To(to)
+	return converted
+}
+
+// To returns v converted to unit.
+func (v Value) To(unit Unit) (Value, error) {
+	value, err := Convert(v.Value, v.Unit, unit)
+	if err != nil {
+		return v, err
+	}
+	return Value{Value: value, Unit: unit}, nil
+}
+
+// String formats v with the usual precision of its unit,
+// e.g. "21.5 °C", "29.92 inHg" or "41%".
+func (v Value) String() string {
+	decimals := -1
+	if v.Unit.valid() {
+		decimals = unitInfo[v.Unit].decimals
+	}
+	return v.Format(decimals)
+}
+
+// Format formats v with the given number of decimals,
+// or as few as needed when decimals is negative.
+func (v Value) Format(decimals int) string {
+	number := strconv.FormatFloat(v.Value, 'f', decimals, 64)
+	switch symbol := v.Unit.Symbol(); symbol {
+	case "":
+		return number
+	case "%", "°":
+		return number + symbol
+	default:
+		return number + " " + symbol
+	}
+}
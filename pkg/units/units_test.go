@@ -0,0 +1,97 @@
+package units
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Convert(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to Unit
+		expected float64
+	}{
+		{32, Fahrenheit, Celsius, 0},
+		{212, Fahrenheit, Celsius, 100},
+		{-40, Celsius, Fahrenheit, -40},
+		{29.92, InHg, HPa, 1013.21},
+		{1013.25, HPa, MmHg, 760},
+		{1, Inch, Millimeter, 25.4},
+		{0.5, InchPerHour, MillimeterPerHour, 12.7},
+		{10, MilesPerHour, KilometersPerHour, 16.0934},
+		{36, KilometersPerHour, MetersPerSecond, 10},
+		{10, Knots, MilesPerHour, 11.5078},
+		{10, Kilometer, Mile, 6.2137},
+		{500, WattsPerSquareMeter, WattsPerSquareMeter, 500},
+	}
+
+	for _, tt := range tests {
+		converted, err := Convert(tt.value, tt.from, tt.to)
+		require.Nil(t, err)
+		require.InDelta(t, tt.expected, converted, 0.01, "%v %v to %v", tt.value, tt.from, tt.to)
+	}
+}
+
+func Test_Convert_DifferentQuantities_ReturnsErrIncompatible(t *testing.T) {
+	_, err := Convert(1, Inch, Mile)
+
+	require.True(t, errors.Is(err, ErrIncompatible))
+}
+
+func Test_Convert_PartsPerMillionToMicrograms_ReturnsErrIncompatible(t *testing.T) {
+	_, err := Convert(400, PartsPerMillion, MicrogramsPerCubicMeter)
+
+	require.True(t, errors.Is(err, ErrIncompatible))
+}
+
+func Test_UnitSystem_Unit(t *testing.T) {
+	require.Equal(t, Fahrenheit, US.Unit(Temperature))
+	require.Equal(t, Celsius, Metric.Unit(Temperature))
+	require.Equal(t, KilometersPerHour, Metric.Unit(WindSpeed))
+	require.Equal(t, MetersPerSecond, MetricWx.Unit(WindSpeed))
+	require.Equal(t, MilesPerHour, UK.Unit(WindSpeed))
+	require.Equal(t, HPa, UK.Unit(Pressure))
+	require.Equal(t, Mile, UK.Unit(Distance))
+	require.Equal(t, None, Metric.Unit(Humidity))
+}
+
+func Test_ParseUnitSystem(t *testing.T) {
+	for _, system := range []UnitSystem{US, Metric, MetricWx, UK} {
+		parsed, err := ParseUnitSystem(system.String())
+		require.Nil(t, err)
+		require.Equal(t, system, parsed)
+	}
+	parsed, err := ParseUnitSystem("MetricWX")
+	require.Nil(t, err)
+	require.Equal(t, MetricWx, parsed)
+
+	_, err = ParseUnitSystem("imperial")
+	require.NotNil(t, err)
+}
+
+func Test_Value_In(t *testing.T) {
+	require.Equal(t, Value{Value: 100, Unit: Celsius}, Value{Value: 212, Unit: Fahrenheit}.In(Metric))
+	require.Equal(t, Value{Value: 41, Unit: Percent}, Value{Value: 41, Unit: Percent}.In(Metric))
+}
+
+func Test_Value_String(t *testing.T) {
+	tests := []struct {
+		value    Value
+		expected string
+	}{
+		{Value{21.54, Celsius}, "21.5 °C"},
+		{Value{29.921, InHg}, "29.92 inHg"},
+		{Value{1013.25, HPa}, "1013.2 hPa"},
+		{Value{41, Percent}, "41%"},
+		{Value{234, Degree}, "234°"},
+		{Value{331.57, WattsPerSquareMeter}, "332 W/m²"},
+		{Value{3, Index}, "3"},
+		{Value{1.25, None}, "1.25"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, tt.value.String())
+	}
+}